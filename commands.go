@@ -0,0 +1,285 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"outline-hexo-connector/internal/config"
+	"outline-hexo-connector/internal/generator"
+	"outline-hexo-connector/internal/hexo"
+	"outline-hexo-connector/internal/hugo"
+	"outline-hexo-connector/internal/outline"
+	"outline-hexo-connector/internal/processor"
+	"outline-hexo-connector/internal/state"
+	"outline-hexo-connector/internal/test"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+func mustLoadConfig(configFile string) *config.Config {
+	cfg, err := config.LoadConfig(configFile)
+	if err != nil {
+		log.Fatalf("Error loading config - %v", err)
+	}
+	err = cfg.Validate()
+	if err != nil {
+		log.Fatalf("Invalid config %s - %v", configFile, err)
+	}
+	log.Printf("Config loaded from %s", configFile)
+	return cfg
+}
+
+func mustOpenStore(cfg *config.Config) *state.Store {
+	store, err := state.Open(cfg.DataDir)
+	if err != nil {
+		log.Fatalf("Error opening document state - %v", err)
+	}
+	return store
+}
+
+// mustLockDataDir keeps a second connector process, such as sync next to the running
+// server, from writing the same state files.
+func mustLockDataDir(cfg *config.Config) func() error {
+	release, err := state.Lock(cfg.DataDir)
+	if errors.Is(err, state.ErrLocked) {
+		log.Fatalf("Error locking %s - %v - Stop the running connector first", cfg.DataDir, err)
+	} else if err != nil {
+		log.Fatalf("Error locking %s - %v", cfg.DataDir, err)
+	}
+	return release
+}
+
+func mustNewGenerator(cfg *config.Config) generator.Generator {
+	switch cfg.Generator {
+	case config.GeneratorHexo:
+		gen, err := hexo.NewGenerator(cfg)
+		if err != nil {
+			log.Fatalf("Error loading Hexo post template - %v", err)
+		}
+		return gen
+	case config.GeneratorHugo:
+		return hugo.NewGenerator(cfg)
+	}
+	log.Fatalf("Unknown generator - %s", cfg.Generator)
+	return nil
+}
+
+func mustNewPipeline(cfg *config.Config) *processor.Pipeline {
+	pipeline, err := processor.NewPipeline(cfg.ProcessorStages)
+	if err != nil {
+		log.Fatalf("Error setting up Markdown processing - %v", err)
+	}
+	return pipeline
+}
+
+// site is one blog with the generator and client writing its posts.
+type site struct {
+	cfg       *config.Config
+	generator generator.Generator
+	client    *outline.Client
+}
+
+func mustOpenSite(cfg *config.Config, siteCfg config.SiteConfig) *site {
+	s := &site{cfg: cfg.ForSite(siteCfg)}
+	s.generator = mustNewGenerator(s.cfg)
+	s.client = outline.NewClient(s.cfg, s.generator, mustNewPipeline(s.cfg), mustOpenStore(s.cfg))
+	s.generator.SetBeforeBuild(s.client.WriteRedirects)
+	return s
+}
+
+func mustOpenSites(cfg *config.Config) []*site {
+	var sites []*site
+	for _, siteCfg := range cfg.AllSites() {
+		sites = append(sites, mustOpenSite(cfg, siteCfg))
+	}
+	return sites
+}
+
+// mustSelectSite opens the site called name, or the first one if name is empty.
+func mustSelectSite(cfg *config.Config, name string) *site {
+	for _, siteCfg := range cfg.AllSites() {
+		if name == "" || siteCfg.Name == name {
+			return mustOpenSite(cfg, siteCfg)
+		}
+	}
+	log.Fatalf("Unknown site - %s", name)
+	return nil
+}
+
+func mustBuild(gen generator.Generator) {
+	log.Printf("Starting site build")
+	err := gen.Build()
+	if err != nil {
+		log.Fatalf("Error building site - %v", err)
+	}
+	log.Printf("Site build completed")
+}
+
+func runSync(ctx context.Context, configFile string) {
+	cfg := mustLoadConfig(configFile)
+	defer mustLockDataDir(cfg)()
+	for _, s := range mustOpenSites(cfg) {
+		synced, err := s.client.Sync(ctx)
+		if err != nil {
+			log.Fatalf("Error syncing collection - %v", err)
+		}
+		log.Printf("Synced %d documents", synced)
+		mustBuild(s.generator)
+	}
+}
+
+func runReconcile(ctx context.Context, configFile string) {
+	cfg := mustLoadConfig(configFile)
+	defer mustLockDataDir(cfg)()
+	for _, s := range mustOpenSites(cfg) {
+		result, err := s.client.Reconcile(ctx)
+		if err != nil {
+			log.Fatalf("Error reconciling posts - %v", err)
+		}
+		log.Printf("Reconcile finished - %d created, %d updated, %d removed", result.Created, result.Updated, result.Removed)
+		if result.Changed() {
+			mustBuild(s.generator)
+		}
+	}
+}
+
+// readLocalDocument reads a Markdown export of a document. Outline exports start with
+// the title as a level one heading.
+func readLocalDocument(path string) (*outline.DocumentPayload, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	modified := info.ModTime().UTC().Format(time.RFC3339Nano)
+	doc := &outline.DocumentPayload{
+		ID:        name,
+		Title:     name,
+		Text:      string(content),
+		CreatedAt: modified,
+		UpdatedAt: modified,
+	}
+	if heading, rest, _ := strings.Cut(doc.Text, "\n"); strings.HasPrefix(heading, "# ") {
+		doc.Title = strings.TrimSpace(strings.TrimPrefix(heading, "# "))
+		doc.Text = strings.TrimLeft(rest, "\n")
+	}
+	return doc, nil
+}
+
+func runRender(ctx context.Context, configFile string, siteName string, target string) {
+	if target == "" {
+		log.Fatalf("Usage - render <document-id|file.md>")
+	}
+	outlineClient := mustSelectSite(mustLoadConfig(configFile), siteName).client
+
+	var doc *outline.DocumentPayload
+	var err error
+	if _, statErr := os.Stat(target); statErr == nil {
+		doc, err = readLocalDocument(target)
+	} else {
+		doc, err = outlineClient.LoadDocument(ctx, target)
+	}
+	if err != nil {
+		log.Fatalf("Error loading document %s - %v", target, err)
+	}
+
+	preview, err := outlineClient.Preview(ctx, doc)
+	if err != nil {
+		log.Fatalf("Error rendering document %s - %v", target, err)
+	}
+
+	fmt.Printf("===== %s =====\n", preview.Name)
+	fmt.Print(preview.Content)
+
+	fmt.Println("===== Directives =====")
+	for _, directive := range preview.Processed.Directives {
+		fmt.Println(directive)
+	}
+
+	fmt.Println("===== Attachments =====")
+	ids := make([]string, 0, len(preview.Processed.Attachments))
+	for id := range preview.Processed.Attachments {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		fmt.Printf("%s -> %s\n", id, preview.Processed.Attachments[id])
+	}
+
+	fmt.Println("===== Warnings =====")
+	for _, warning := range preview.Processed.Warnings {
+		fmt.Println(warning)
+	}
+}
+
+func runReplay(ctx context.Context, configFile string, url string, paths []string) {
+	if len(paths) == 0 {
+		log.Fatalf("Usage - replay <fixture.json|dir>...")
+	}
+	cfg := mustLoadConfig(configFile)
+	if cfg.OutlineWebhookSecret == "" {
+		log.Fatalf("No webhook secret configured in config")
+	}
+
+	err := test.Replay(ctx, url, cfg.OutlineWebhookSecret, paths)
+	if err != nil {
+		log.Fatalf("Error replaying fixtures - %v", err)
+	}
+}
+
+// runConfigCheck validates the config, together with what only the packages using it can
+// check, and lists every problem found.
+func runConfigCheck(configFile string) {
+	cfg, err := config.LoadConfig(configFile)
+	if err != nil {
+		log.Fatalf("Error loading config - %v", err)
+	}
+
+	var problems []string
+	err = cfg.Validate()
+	var invalid *config.ValidationError
+	if errors.As(err, &invalid) {
+		problems = invalid.Problems
+	} else if err != nil {
+		log.Fatalf("Error validating config - %v", err)
+	}
+
+	sites := cfg.AllSites()
+	for _, site := range sites {
+		prefix := ""
+		if site.Name != "" {
+			prefix = "Site " + site.Name + " - "
+		}
+		_, err := processor.NewPipeline(site.ProcessorStages)
+		if err != nil {
+			problems = append(problems, prefix+"Processor_Stages - "+err.Error())
+		}
+		if site.Generator == config.GeneratorHexo && site.HexoPostTemplate != "" {
+			// A missing template is reported by Validate already
+			if _, statErr := os.Stat(site.HexoPostTemplate); statErr == nil {
+				_, err := hexo.LoadPostTemplate(site.HexoPostTemplate)
+				if err != nil {
+					problems = append(problems, prefix+"Hexo_Post_Template - "+err.Error())
+				}
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		fmt.Printf("%s has %d problems:\n", configFile, len(problems))
+		for _, problem := range problems {
+			fmt.Printf("  - %s\n", problem)
+		}
+		os.Exit(1)
+	}
+	fmt.Printf("%s is valid, %d sites\n", configFile, len(sites))
+}
@@ -4,4 +4,9 @@ go 1.21
 
 require github.com/spf13/pflag v1.0.10
 
-require gopkg.in/yaml.v3 v3.0.1
+require (
+	github.com/mozillazg/go-pinyin v0.20.0
+	github.com/yuin/goldmark v1.7.8
+	golang.org/x/text v0.14.0
+	gopkg.in/yaml.v3 v3.0.1
+)
@@ -1,22 +1,86 @@
 package config
 
 import (
+	"errors"
+	"io"
 	"os"
+	"path/filepath"
+	"reflect"
 
 	"gopkg.in/yaml.v3"
 )
 
+const (
+	GeneratorHexo = "hexo"
+	GeneratorHugo = "hugo"
+)
+
+const (
+	PostFileNameID   = "id"
+	PostFileNameSlug = "slug"
+)
+
+const (
+	AttachmentModeLink     = "link"
+	AttachmentModeDownload = "download"
+)
+
 type Config struct {
-	OutlineAPIKey                string `yaml:"Outline_API_Key"`
-	OutlineAPIURL                string `yaml:"Outline_API_URL"`
-	OutlineWebhookSecret         string `yaml:"Outline_Webhook_Secret"`
-	OutlineCollectionUsedForBlog string `yaml:"Outline_Collection_Used_For_Blog"`
-	OutlineUnpublishWhenUpdated  bool   `yaml:"Outline_Unpublish_When_Updated"`
-	HexoBuildInterval            int    `yaml:"Hexo_Build_Interval"`
-	HexoBuildCommand             string `yaml:"Hexo_Build_Command"`
-	HexoSourcePostDir            string `yaml:"Hexo_Source_Post_Dir"`
+	OutlineAPIKey               string `yaml:"Outline_API_Key"`
+	OutlineAPIURL               string `yaml:"Outline_API_URL"`
+	OutlineWebhookSecret        string `yaml:"Outline_Webhook_Secret"`
+	OutlineUnpublishWhenUpdated bool   `yaml:"Outline_Unpublish_When_Updated"`
+	OutlineReconcileInterval    int    `yaml:"Outline_Reconcile_Interval"`
+	OutlineCacheTTL             int    `yaml:"Outline_Cache_TTL"`
+	DataDir                     string `yaml:"Data_Dir"`
+	QueueWorkers                int    `yaml:"Queue_Workers"`
+	// The top level is a site itself, used when no Sites are listed
+	SiteConfig `yaml:",inline"`
+	// Sites start from the settings of the top level site
+	Sites []SiteConfig `yaml:"-"`
 }
 
+// SiteConfig is the configuration of one blog.
+type SiteConfig struct {
+	Name                         string        `yaml:"Name"`
+	OutlineCollectionUsedForBlog string        `yaml:"Outline_Collection_Used_For_Blog"`
+	OutlineCollections           []string      `yaml:"Outline_Collections"`
+	OutlineCollectionIDs         []string      `yaml:"Outline_Collection_IDs"`
+	OutlineCategoryMaxDepth      int           `yaml:"Outline_Category_Max_Depth"`
+	OutlineTopLevelAsPost        bool          `yaml:"Outline_Top_Level_As_Post"`
+	HexoBuildInterval            int           `yaml:"Hexo_Build_Interval"`
+	HexoBuildCommand             string        `yaml:"Hexo_Build_Command"`
+	HexoSourcePostDir            string        `yaml:"Hexo_Source_Post_Dir"`
+	HexoPostTemplate             string        `yaml:"Hexo_Post_Template"`
+	Generator                    string        `yaml:"Generator"`
+	PostFileName                 string        `yaml:"Post_File_Name"`
+	HugoBuildInterval            int           `yaml:"Hugo_Build_Interval"`
+	HugoBuildCommand             string        `yaml:"Hugo_Build_Command"`
+	HugoContentDir               string        `yaml:"Hugo_Content_Dir"`
+	HugoFrontMatterFormat        string        `yaml:"Hugo_Front_Matter_Format"`
+	HugoAttachmentDir            string        `yaml:"Hugo_Attachment_Dir"`
+	HugoAttachmentURLPrefix      string        `yaml:"Hugo_Attachment_URL_Prefix"`
+	HexoAttachmentDir            string        `yaml:"Hexo_Attachment_Dir"`
+	HexoAttachmentURLPrefix      string        `yaml:"Hexo_Attachment_URL_Prefix"`
+	AttachmentMode               string        `yaml:"Attachment_Mode"`
+	RedirectPermalink            string        `yaml:"Redirect_Permalink"`
+	RedirectRoot                 string        `yaml:"Redirect_Root"`
+	RedirectOutputDir            string        `yaml:"Redirect_Output_Dir"`
+	ProcessorStages              []StageConfig `yaml:"Processor_Stages"`
+}
+
+// siteOwnKeys are the keys a listed site does not inherit from the top level.
+var siteOwnKeys = map[string]bool{
+	"Name":                             true,
+	"Outline_Collection_Used_For_Blog": true,
+	"Outline_Collections":              true,
+	"Outline_Collection_IDs":           true,
+}
+
+// LoadConfig reads the config file at path and overrides it with the OHC_ environment
+// variables, which win over the top level and the sites of the file alike. Variables
+// named by SiteEnvName only apply to their site. The config is not usable before Validate
+// applied the defaults.
 func LoadConfig(path string) (*Config, error) {
 	config := &Config{}
 	file, err := os.Open(path)
@@ -25,10 +89,141 @@ func LoadConfig(path string) (*Config, error) {
 	}
 	defer file.Close()
 
+	var root yaml.Node
 	d := yaml.NewDecoder(file)
-	if err := d.Decode(&config); err != nil {
+	// An empty file is fine when everything is set in the environment
+	if err := d.Decode(&root); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	if err := root.Decode(config); err != nil {
 		return nil, err
 	}
 
+	var sites struct {
+		Sites []yaml.Node `yaml:"Sites"`
+	}
+	if err := root.Decode(&sites); err != nil {
+		return nil, err
+	}
+	for _, node := range sites.Sites {
+		site := config.SiteConfig
+		eachKey(reflect.ValueOf(&site).Elem(), func(key string, field reflect.Value) error {
+			if siteOwnKeys[key] {
+				field.SetZero()
+			}
+			return nil
+		})
+		if err := node.Decode(&site); err != nil {
+			return nil, err
+		}
+		config.Sites = append(config.Sites, site)
+	}
+
+	// After the sites, so the environment wins over what a site sets in the file
+	if err := applyEnv(reflect.ValueOf(config), EnvName, nil); err != nil {
+		return nil, err
+	}
+	for i := range config.Sites {
+		site := &config.Sites[i]
+		if err := applyEnv(reflect.ValueOf(site), EnvName, siteOwnKeys); err != nil {
+			return nil, err
+		}
+		if site.Name == "" {
+			continue
+		}
+		siteEnvName := func(key string) string {
+			return SiteEnvName(site.Name, key)
+		}
+		if err := applyEnv(reflect.ValueOf(site), siteEnvName, map[string]bool{"Name": true}); err != nil {
+			return nil, err
+		}
+	}
+
 	return config, nil
 }
+
+// AllSites returns the listed Sites, or the top level site when there are none.
+func (c *Config) AllSites() []SiteConfig {
+	if len(c.Sites) == 0 {
+		return []SiteConfig{c.SiteConfig}
+	}
+	return c.Sites
+}
+
+// ForSite returns the configuration of site together with the shared settings. Listed
+// sites keep their state in a directory of their own under Data_Dir.
+func (c *Config) ForSite(site SiteConfig) *Config {
+	siteCfg := *c
+	siteCfg.SiteConfig = site
+	siteCfg.Sites = nil
+	if site.Name != "" {
+		siteCfg.DataDir = filepath.Join(c.DataDir, site.Name)
+	}
+	return &siteCfg
+}
+
+// CollectionNames returns the names of the collections the site publishes, besides
+// the ones set by ID.
+func (s *SiteConfig) CollectionNames() []string {
+	var names []string
+	if s.OutlineCollectionUsedForBlog != "" {
+		names = append(names, s.OutlineCollectionUsedForBlog)
+	}
+	return append(names, s.OutlineCollections...)
+}
+
+// AttachmentDir returns the directory downloaded attachments are stored in for the generator.
+func (s *SiteConfig) AttachmentDir() string {
+	if s.Generator == GeneratorHugo {
+		return s.HugoAttachmentDir
+	}
+	return s.HexoAttachmentDir
+}
+
+// AttachmentURLPrefix returns the site URL of AttachmentDir.
+func (s *SiteConfig) AttachmentURLPrefix() string {
+	if s.Generator == GeneratorHugo {
+		return s.HugoAttachmentURLPrefix
+	}
+	return s.HexoAttachmentURLPrefix
+}
+
+func (config *SiteConfig) applyDefaults() {
+	if config.Generator == "" {
+		config.Generator = GeneratorHexo
+	}
+	if config.PostFileName == "" {
+		config.PostFileName = PostFileNameID
+	}
+	if config.HugoFrontMatterFormat == "" {
+		config.HugoFrontMatterFormat = "yaml"
+	}
+	if config.AttachmentMode == "" {
+		config.AttachmentMode = AttachmentModeLink
+	}
+	if config.Generator == GeneratorHugo {
+		if config.HugoAttachmentDir == "" {
+			config.HugoAttachmentDir = filepath.Join(filepath.Dir(filepath.Dir(config.HugoContentDir)), "static", "images")
+		}
+		if config.HugoAttachmentURLPrefix == "" {
+			config.HugoAttachmentURLPrefix = "/images"
+		}
+	} else {
+		if config.HexoAttachmentDir == "" {
+			config.HexoAttachmentDir = filepath.Join(filepath.Dir(config.HexoSourcePostDir), "images")
+		}
+		if config.HexoAttachmentURLPrefix == "" {
+			config.HexoAttachmentURLPrefix = "/images"
+		}
+	}
+	if config.RedirectRoot == "" {
+		config.RedirectRoot = "/"
+	}
+	if config.RedirectOutputDir == "" {
+		if config.Generator == GeneratorHugo {
+			config.RedirectOutputDir = filepath.Join(filepath.Dir(filepath.Dir(config.HugoContentDir)), "static")
+		} else {
+			config.RedirectOutputDir = filepath.Dir(config.HexoSourcePostDir)
+		}
+	}
+}
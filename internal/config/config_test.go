@@ -0,0 +1,221 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestEnvOverridesSites(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	content := `
+Outline_Collection_Used_For_Blog: Top
+Hexo_Build_Command: top build
+Hexo_Build_Interval: 30
+Sites:
+  - Name: tech-blog
+    Outline_Collection_Used_For_Blog: Tech
+    Hexo_Build_Command: tech build
+    Hexo_Source_Post_Dir: tech/_posts
+  - Name: notes
+    Outline_Collection_Used_For_Blog: Notes
+    Hexo_Source_Post_Dir: notes/_posts
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("OHC_HEXO_BUILD_COMMAND", "env build")
+	t.Setenv("OHC_OUTLINE_COLLECTION_USED_FOR_BLOG", "Env")
+	t.Setenv("OHC_SITES_TECH_BLOG_HEXO_SOURCE_POST_DIR", "env/_posts")
+	t.Setenv("OHC_SITES_TECH_BLOG_OUTLINE_COLLECTIONS", "[A, B]")
+	t.Setenv("OHC_SITES_NOTES_HEXO_BUILD_INTERVAL", "5")
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.HexoBuildCommand != "env build" || cfg.OutlineCollectionUsedForBlog != "Env" {
+		t.Errorf("top level = %q, %q", cfg.HexoBuildCommand, cfg.OutlineCollectionUsedForBlog)
+	}
+
+	tech, notes := cfg.Sites[0], cfg.Sites[1]
+	if tech.HexoBuildCommand != "env build" || notes.HexoBuildCommand != "env build" {
+		t.Errorf("build commands = %q, %q, want the environment over the sites", tech.HexoBuildCommand, notes.HexoBuildCommand)
+	}
+	if tech.OutlineCollectionUsedForBlog != "Tech" || notes.OutlineCollectionUsedForBlog != "Notes" {
+		t.Errorf("collections = %q, %q, want the sites' own", tech.OutlineCollectionUsedForBlog, notes.OutlineCollectionUsedForBlog)
+	}
+	if tech.HexoSourcePostDir != "env/_posts" || notes.HexoSourcePostDir != "notes/_posts" {
+		t.Errorf("post dirs = %q, %q", tech.HexoSourcePostDir, notes.HexoSourcePostDir)
+	}
+	if !reflect.DeepEqual(tech.OutlineCollections, []string{"A", "B"}) || notes.OutlineCollections != nil {
+		t.Errorf("collections = %v, %v", tech.OutlineCollections, notes.OutlineCollections)
+	}
+	if tech.HexoBuildInterval != 30 || notes.HexoBuildInterval != 5 {
+		t.Errorf("build intervals = %d, %d", tech.HexoBuildInterval, notes.HexoBuildInterval)
+	}
+}
+
+func TestSiteEnvName(t *testing.T) {
+	if name := SiteEnvName("tech-blog.v2", "Hexo_Build_Command"); name != "OHC_SITES_TECH_BLOG_V2_HEXO_BUILD_COMMAND" {
+		t.Errorf("SiteEnvName = %s", name)
+	}
+}
+
+func TestValidateStagesWithHugo(t *testing.T) {
+	tests := []struct {
+		stages    string
+		permalink string
+		want      string
+	}{
+		{"", "", "Redirect_Permalink is not set"},
+		{"", ":title/", ""},
+		{"[{name: doclinks, target: post_link}]", ":title/", "post_link is a Hexo tag"},
+		{"[unescape]", "", ""},
+		{"[{name: outline, notice: hexo}]", "", "notice target hexo is a Hexo tag"},
+		{"[{name: outline, notice: html}]", "", ""},
+	}
+	for _, tt := range tests {
+		cfg := &Config{}
+		cfg.Generator = GeneratorHugo
+		cfg.RedirectPermalink = tt.permalink
+		if err := yaml.Unmarshal([]byte(tt.stages), &cfg.ProcessorStages); err != nil {
+			t.Fatal(err)
+		}
+		err := cfg.Validate()
+		var problems []string
+		if validation, ok := err.(*ValidationError); ok {
+			problems = validation.Problems
+		}
+		found := ""
+		for _, problem := range problems {
+			if strings.Contains(problem, "doclinks") || strings.Contains(problem, "notice") {
+				found = problem
+			}
+		}
+		if tt.want == "" && found != "" || !strings.Contains(found, tt.want) {
+			t.Errorf("stages %q, permalink %q - got %q, want %q", tt.stages, tt.permalink, found, tt.want)
+		}
+	}
+}
+
+// validConfig returns a config that passes Validate, with its post dir under a temp dir.
+func validConfig(t *testing.T) *Config {
+	t.Helper()
+	dir := t.TempDir()
+	postDir := filepath.Join(dir, "source", "_posts")
+	if err := os.MkdirAll(postDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &Config{
+		OutlineAPIKey:        "key",
+		OutlineAPIURL:        "https://outline.example.com/api",
+		OutlineWebhookSecret: "secret",
+	}
+	cfg.OutlineCollectionUsedForBlog = "Blog"
+	cfg.HexoSourcePostDir = postDir
+	cfg.HexoBuildCommand = "hexo generate"
+	cfg.HexoBuildInterval = 30
+	return cfg
+}
+
+func TestValidateDefaults(t *testing.T) {
+	cfg := validConfig(t)
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	sourceDir := filepath.Dir(cfg.HexoSourcePostDir)
+	got := []any{cfg.DataDir, cfg.QueueWorkers, cfg.OutlineCacheTTL, cfg.Generator, cfg.PostFileName,
+		cfg.AttachmentMode, cfg.HexoAttachmentDir, cfg.HexoAttachmentURLPrefix, cfg.RedirectRoot, cfg.RedirectOutputDir}
+	want := []any{"data", 1, 300, GeneratorHexo, PostFileNameID,
+		AttachmentModeLink, filepath.Join(sourceDir, "images"), "/images", "/", sourceDir}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("defaults\n got %v\nwant %v", got, want)
+	}
+
+	// Set values are kept, a negative cache TTL disables the cache
+	cfg = validConfig(t)
+	cfg.QueueWorkers = 4
+	cfg.OutlineCacheTTL = -1
+	cfg.HexoAttachmentURLPrefix = "/files"
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.QueueWorkers != 4 || cfg.OutlineCacheTTL != -1 || cfg.HexoAttachmentURLPrefix != "/files" {
+		t.Errorf("set values = %d, %d, %q", cfg.QueueWorkers, cfg.OutlineCacheTTL, cfg.HexoAttachmentURLPrefix)
+	}
+}
+
+func TestValidateSiteDefaults(t *testing.T) {
+	cfg := validConfig(t)
+	contentDir := filepath.Join(t.TempDir(), "content", "posts")
+	if err := os.MkdirAll(contentDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	hugo := cfg.SiteConfig
+	hugo.Name = "hugo"
+	hugo.Generator = GeneratorHugo
+	hugo.HugoContentDir = contentDir
+	hugo.HugoBuildCommand = "hugo"
+	hugo.HugoBuildInterval = 30
+	hugo.RedirectPermalink = ":title/"
+	hexo := cfg.SiteConfig
+	hexo.Name = "hexo"
+	cfg.Sites = []SiteConfig{hugo, hexo}
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	site := cfg.Sites[0]
+	siteDir := filepath.Dir(filepath.Dir(contentDir))
+	if site.HugoFrontMatterFormat != "yaml" || site.AttachmentDir() != filepath.Join(siteDir, "static", "images") ||
+		site.AttachmentURLPrefix() != "/images" || site.RedirectOutputDir != filepath.Join(siteDir, "static") {
+		t.Errorf("Hugo site defaults = %q, %q, %q, %q", site.HugoFrontMatterFormat, site.AttachmentDir(), site.AttachmentURLPrefix(), site.RedirectOutputDir)
+	}
+	if site.HexoAttachmentDir != "" {
+		t.Errorf("Hugo site got the Hexo attachment dir %q", site.HexoAttachmentDir)
+	}
+	if site := cfg.Sites[1]; site.AttachmentDir() != filepath.Join(filepath.Dir(cfg.HexoSourcePostDir), "images") {
+		t.Errorf("Hexo site attachment dir = %q", site.AttachmentDir())
+	}
+}
+
+func TestValidateCollectsProblems(t *testing.T) {
+	cfg := &Config{OutlineAPIURL: "outline.example.com", OutlineReconcileInterval: -1}
+	cfg.Sites = []SiteConfig{
+		{Name: "a", Generator: "jekyll", PostFileName: "title"},
+		{Name: "a", OutlineCollectionUsedForBlog: "Blog", HexoSourcePostDir: filepath.Join(t.TempDir(), "missing")},
+	}
+
+	err := cfg.Validate()
+	validation, ok := err.(*ValidationError)
+	if !ok {
+		t.Fatalf("Validate = %v, want a ValidationError", err)
+	}
+	want := []string{
+		"Outline_API_Key is not set",
+		`Outline_API_URL "outline.example.com" is not an http(s) URL`,
+		"Outline_Webhook_Secret is not set",
+		"Outline_Reconcile_Interval must not be negative",
+		"Site a is defined twice",
+		"Site a - No collection set",
+		`Site a - Generator must be hexo or hugo, not "jekyll"`,
+		`Site a - Post_File_Name must be id or slug, not "title"`,
+		"Site a - Hexo_Source_Post_Dir " + cfg.Sites[1].HexoSourcePostDir,
+		"Site a - Hexo_Build_Command is not set",
+		"Site a - Hexo_Build_Interval must be at least 1 second",
+	}
+	for _, problem := range want {
+		found := false
+		for _, got := range validation.Problems {
+			found = found || strings.HasPrefix(got, problem)
+		}
+		if !found {
+			t.Errorf("problem %q missing from\n%s", problem, validation)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+)
+
+// secretKeys are reported as changed without their values.
+var secretKeys = map[string]bool{
+	"Outline_API_Key":        true,
+	"Outline_Webhook_Secret": true,
+}
+
+// Diff describes every setting that differs between old and new, one line each.
+// Settings of Sites are prefixed with the name of the site.
+func Diff(old *Config, new *Config) []string {
+	var changes []string
+	changes = diffKeys(changes, "", reflect.ValueOf(old).Elem(), reflect.ValueOf(new).Elem())
+
+	oldSites := make(map[string]*SiteConfig, len(old.Sites))
+	for i := range old.Sites {
+		oldSites[old.Sites[i].Name] = &old.Sites[i]
+	}
+	for i := range new.Sites {
+		site := &new.Sites[i]
+		oldSite, ok := oldSites[site.Name]
+		if !ok {
+			changes = append(changes, fmt.Sprintf("Sites.%s added", site.Name))
+			continue
+		}
+		delete(oldSites, site.Name)
+		changes = diffKeys(changes, "Sites."+site.Name+".", reflect.ValueOf(oldSite).Elem(), reflect.ValueOf(site).Elem())
+	}
+	for _, site := range old.Sites {
+		if _, removed := oldSites[site.Name]; removed {
+			changes = append(changes, fmt.Sprintf("Sites.%s removed", site.Name))
+		}
+	}
+	return changes
+}
+
+func diffKeys(changes []string, prefix string, old reflect.Value, new reflect.Value) []string {
+	newValues := make(map[string]string)
+	eachKey(new, func(key string, field reflect.Value) error {
+		newValues[key] = formatValue(field)
+		return nil
+	})
+	eachKey(old, func(key string, field reflect.Value) error {
+		oldValue, newValue := formatValue(field), newValues[key]
+		switch {
+		case oldValue == newValue:
+		case secretKeys[key]:
+			changes = append(changes, fmt.Sprintf("%s%s changed", prefix, key))
+		default:
+			changes = append(changes, fmt.Sprintf("%s%s: %s -> %s", prefix, key, oldValue, newValue))
+		}
+		return nil
+	})
+	return changes
+}
+
+// formatValue returns the value of a setting on one line.
+func formatValue(v reflect.Value) string {
+	if v.Kind() == reflect.String {
+		return strconv.Quote(v.String())
+	}
+	// Through YAML for the stage options, then as compact JSON
+	out, err := yaml.Marshal(v.Interface())
+	if err != nil {
+		return fmt.Sprint(v.Interface())
+	}
+	var generic any
+	if err := yaml.Unmarshal(out, &generic); err != nil {
+		return fmt.Sprint(v.Interface())
+	}
+	line, err := json.Marshal(generic)
+	if err != nil {
+		return fmt.Sprint(v.Interface())
+	}
+	return string(line)
+}
@@ -0,0 +1,95 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix starts the environment variables overriding config keys.
+const EnvPrefix = "OHC_"
+
+// EnvName returns the environment variable of the config key key, e.g.
+// OHC_OUTLINE_API_KEY for Outline_API_Key.
+func EnvName(key string) string {
+	return EnvPrefix + strings.ToUpper(key)
+}
+
+// SiteEnvName returns the environment variable of the config key key of the site called
+// site, e.g. OHC_SITES_TECH_BLOG_HEXO_SOURCE_POST_DIR for Hexo_Source_Post_Dir of tech-blog.
+// Anything in the site name that is not a letter or digit becomes an underscore.
+func SiteEnvName(site string, key string) string {
+	name := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '_'
+	}, site)
+	return EnvPrefix + "SITES_" + strings.ToUpper(name) + "_" + strings.ToUpper(key)
+}
+
+// lookupEnv returns the value of the environment variable name, or the content of the
+// file named by name_FILE, as Docker and Kubernetes mount secrets.
+func lookupEnv(name string) (string, bool, error) {
+	if value, ok := os.LookupEnv(name); ok {
+		return value, true, nil
+	}
+	path, ok := os.LookupEnv(name + "_FILE")
+	if !ok {
+		return "", false, nil
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", false, fmt.Errorf("Error reading %s_FILE - %w", name, err)
+	}
+	return strings.TrimRight(string(content), "\r\n"), true, nil
+}
+
+// applyEnv overrides the fields of the struct v points to with the environment variables
+// envName gives for their keys, keys in skip are left alone. Strings are taken as they
+// are, other values are parsed as YAML, e.g. OHC_OUTLINE_COLLECTIONS='[Blog, Notes]'.
+func applyEnv(v reflect.Value, envName func(key string) string, skip map[string]bool) error {
+	return eachKey(v.Elem(), func(key string, field reflect.Value) error {
+		if skip[key] {
+			return nil
+		}
+		name := envName(key)
+		value, ok, err := lookupEnv(name)
+		if err != nil || !ok {
+			return err
+		}
+		if field.Kind() == reflect.String {
+			field.SetString(value)
+			return nil
+		}
+		if err := yaml.Unmarshal([]byte(value), field.Addr().Interface()); err != nil {
+			return fmt.Errorf("Error parsing %s - %w", name, err)
+		}
+		return nil
+	})
+}
+
+// eachKey calls fn with every field of the struct v that has a config key, including
+// the fields of inlined structs.
+func eachKey(v reflect.Value, fn func(key string, field reflect.Value) error) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		key, options, _ := strings.Cut(t.Field(i).Tag.Get("yaml"), ",")
+		if options == "inline" {
+			if err := eachKey(v.Field(i), fn); err != nil {
+				return err
+			}
+			continue
+		}
+		if key == "" || key == "-" {
+			continue
+		}
+		if err := fn(key, v.Field(i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package config
+
+import (
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// StageConfig enables a Markdown processing stage. It is written either as just the name
+// of the stage, or as a mapping with the name and the options of the stage.
+type StageConfig struct {
+	Name    string
+	options yaml.Node
+}
+
+func (s *StageConfig) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		s.Name = node.Value
+		return nil
+	}
+
+	var named struct {
+		Name string `yaml:"name"`
+	}
+	if err := node.Decode(&named); err != nil {
+		return err
+	}
+	if named.Name == "" {
+		return fmt.Errorf("Processor stage without name at line %d", node.Line)
+	}
+	s.Name = named.Name
+	s.options = *node
+	return nil
+}
+
+// Decode decodes the options of the stage into v. Without options v is left as is.
+func (s StageConfig) Decode(v any) error {
+	if s.options.Kind == 0 {
+		return nil
+	}
+	return s.options.Decode(v)
+}
+
+func (s StageConfig) MarshalYAML() (any, error) {
+	if s.options.Kind == 0 {
+		return s.Name, nil
+	}
+	return &s.options, nil
+}
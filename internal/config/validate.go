@@ -0,0 +1,200 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// ValidationError lists every problem found in a config.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%d problems found\n  %s", len(e.Problems), strings.Join(e.Problems, "\n  "))
+}
+
+type problems []string
+
+func (p *problems) addf(format string, args ...any) {
+	*p = append(*p, fmt.Sprintf(format, args...))
+}
+
+// Validate applies the defaults and checks the config, so that mistakes show up at
+// startup instead of as failed writes or builds later. All problems are reported at once.
+func (c *Config) Validate() error {
+	// Sites first, they would inherit defaults derived from the top level paths otherwise
+	for i := range c.Sites {
+		c.Sites[i].applyDefaults()
+	}
+	c.SiteConfig.applyDefaults()
+	if c.DataDir == "" {
+		c.DataDir = "data"
+	}
+	if c.QueueWorkers <= 0 {
+		c.QueueWorkers = 1
+	}
+	// A negative TTL disables the cache
+	if c.OutlineCacheTTL == 0 {
+		c.OutlineCacheTTL = 300
+	}
+
+	var p problems
+	if c.OutlineAPIKey == "" {
+		p.addf("Outline_API_Key is not set")
+	}
+	if apiURL, err := url.Parse(c.OutlineAPIURL); c.OutlineAPIURL == "" {
+		p.addf("Outline_API_URL is not set")
+	} else if err != nil || (apiURL.Scheme != "http" && apiURL.Scheme != "https") || apiURL.Host == "" {
+		p.addf("Outline_API_URL %q is not an http(s) URL", c.OutlineAPIURL)
+	}
+	if c.OutlineWebhookSecret == "" {
+		p.addf("Outline_Webhook_Secret is not set")
+	}
+	if c.OutlineReconcileInterval < 0 {
+		p.addf("Outline_Reconcile_Interval must not be negative, 0 disables it")
+	}
+
+	names := make(map[string]bool)
+	envNames := make(map[string]string)
+	for _, site := range c.Sites {
+		if site.Name == "" {
+			p.addf("Site without Name")
+			continue
+		}
+		if names[site.Name] {
+			p.addf("Site %s is defined twice", site.Name)
+		} else if other, ok := envNames[SiteEnvName(site.Name, "")]; ok {
+			p.addf("Sites %s and %s share the environment variables %s*", other, site.Name, SiteEnvName(site.Name, ""))
+		}
+		names[site.Name] = true
+		envNames[SiteEnvName(site.Name, "")] = site.Name
+	}
+	for _, site := range c.AllSites() {
+		site.validate(&p)
+	}
+
+	if len(p) > 0 {
+		return &ValidationError{Problems: p}
+	}
+	return nil
+}
+
+func (s *SiteConfig) validate(p *problems) {
+	addf := p.addf
+	if s.Name != "" {
+		addf = func(format string, args ...any) {
+			p.addf("Site %s - "+format, append([]any{s.Name}, args...)...)
+		}
+	}
+
+	if len(s.CollectionNames()) == 0 && len(s.OutlineCollectionIDs) == 0 {
+		addf("No collection set, use Outline_Collection_Used_For_Blog, Outline_Collections or Outline_Collection_IDs")
+	}
+	if s.OutlineCategoryMaxDepth < 0 {
+		addf("Outline_Category_Max_Depth must not be negative, 0 means no limit")
+	}
+
+	switch s.Generator {
+	case GeneratorHexo:
+		checkDir(addf, "Hexo_Source_Post_Dir", s.HexoSourcePostDir)
+		checkBuild(addf, "Hexo", s.HexoBuildCommand, s.HexoBuildInterval)
+		if s.HexoPostTemplate != "" {
+			if _, err := os.Stat(s.HexoPostTemplate); err != nil {
+				addf("Hexo_Post_Template %s - %v", s.HexoPostTemplate, err)
+			}
+		}
+	case GeneratorHugo:
+		checkDir(addf, "Hugo_Content_Dir", s.HugoContentDir)
+		checkBuild(addf, "Hugo", s.HugoBuildCommand, s.HugoBuildInterval)
+		if s.HugoFrontMatterFormat != "yaml" && s.HugoFrontMatterFormat != "toml" {
+			addf("Hugo_Front_Matter_Format must be yaml or toml, not %q", s.HugoFrontMatterFormat)
+		}
+	default:
+		addf("Generator must be %s or %s, not %q", GeneratorHexo, GeneratorHugo, s.Generator)
+	}
+
+	if doclinks, ok := s.stage("doclinks"); ok && s.Generator == GeneratorHugo {
+		var options struct {
+			Target string `yaml:"target"`
+		}
+		if err := doclinks.Decode(&options); err == nil && options.Target == "post_link" {
+			addf("The doclinks target post_link is a Hexo tag, use target permalink with Hugo")
+		} else if s.RedirectPermalink == "" {
+			addf("Redirect_Permalink is not set, the doclinks stage needs it with Hugo to point links to other documents at their posts")
+		}
+	}
+	if outline, ok := s.stage("outline"); ok && s.Generator == GeneratorHugo {
+		var options struct {
+			Notice string `yaml:"notice"`
+		}
+		if err := outline.Decode(&options); err == nil && options.Notice == "hexo" {
+			addf("The outline notice target hexo is a Hexo tag, use target html with Hugo")
+		}
+	}
+
+	if s.PostFileName != PostFileNameID && s.PostFileName != PostFileNameSlug {
+		addf("Post_File_Name must be %s or %s, not %q", PostFileNameID, PostFileNameSlug, s.PostFileName)
+	}
+	switch s.AttachmentMode {
+	case AttachmentModeLink:
+	case AttachmentModeDownload:
+		if s.Generator == GeneratorHugo && s.HugoAttachmentDir == "" {
+			addf("Hugo_Attachment_Dir is not set")
+		} else if s.Generator != GeneratorHugo && s.HexoAttachmentDir == "" {
+			addf("Hexo_Attachment_Dir is not set")
+		}
+	default:
+		addf("Attachment_Mode must be %s or %s, not %q", AttachmentModeLink, AttachmentModeDownload, s.AttachmentMode)
+	}
+}
+
+// stage returns the entry of the stage called name, false when it does not run. Without
+// Processor_Stages the default stages run, doclinks and outline among them.
+func (s *SiteConfig) stage(name string) (StageConfig, bool) {
+	if len(s.ProcessorStages) == 0 {
+		return StageConfig{Name: name}, name == "doclinks" || name == "outline"
+	}
+	for _, stage := range s.ProcessorStages {
+		if stage.Name == name {
+			return stage, true
+		}
+	}
+	return StageConfig{}, false
+}
+
+// checkDir checks that the directory set as key exists and posts can be written into it.
+func checkDir(addf func(string, ...any), key string, dir string) {
+	if dir == "" {
+		addf("%s is not set", key)
+		return
+	}
+	info, err := os.Stat(dir)
+	if err != nil {
+		addf("%s %s - %v", key, dir, err)
+		return
+	}
+	if !info.IsDir() {
+		addf("%s %s is not a directory", key, dir)
+		return
+	}
+	file, err := os.CreateTemp(dir, ".ohc-check-*")
+	if err != nil {
+		addf("%s %s is not writable - %v", key, dir, err)
+		return
+	}
+	file.Close()
+	os.Remove(file.Name())
+}
+
+// checkBuild checks the build command and the debounce interval of generator.
+func checkBuild(addf func(string, ...any), generator string, command string, interval int) {
+	if strings.TrimSpace(command) == "" {
+		addf("%s_Build_Command is not set", generator)
+	}
+	if interval < 1 {
+		addf("%s_Build_Interval must be at least 1 second, %d would build on every change", generator, interval)
+	}
+}
@@ -0,0 +1,71 @@
+package generator
+
+import (
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FrontMatter is a set of front matter fields that keeps the order they were set in.
+type FrontMatter struct {
+	keys   []string
+	values map[string]any
+}
+
+func NewFrontMatter() *FrontMatter {
+	return &FrontMatter{values: make(map[string]any)}
+}
+
+// Set adds or replaces a field, a replaced field keeps its position.
+func (f *FrontMatter) Set(key string, value any) {
+	if _, ok := f.values[key]; !ok {
+		f.keys = append(f.keys, key)
+	}
+	f.values[key] = value
+}
+
+func (f *FrontMatter) Get(key string) (any, bool) {
+	value, ok := f.values[key]
+	return value, ok
+}
+
+// Keys returns the field names in order.
+func (f *FrontMatter) Keys() []string {
+	return f.keys
+}
+
+// Merge sets every field of other, replacing fields of the same name.
+func (f *FrontMatter) Merge(other *FrontMatter) {
+	if other == nil {
+		return
+	}
+	for _, key := range other.keys {
+		f.Set(key, other.values[key])
+	}
+}
+
+func (f *FrontMatter) MarshalYAML() (any, error) {
+	node := &yaml.Node{Kind: yaml.MappingNode}
+	for _, key := range f.keys {
+		var value yaml.Node
+		err := value.Encode(f.values[key])
+		if err != nil {
+			return nil, err
+		}
+		node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, &value)
+	}
+	return node, nil
+}
+
+// YAML serializes the fields, without the surrounding --- lines.
+func (f *FrontMatter) YAML() (string, error) {
+	var b strings.Builder
+	encoder := yaml.NewEncoder(&b)
+	encoder.SetIndent(2)
+	err := encoder.Encode(f)
+	if err != nil {
+		return "", err
+	}
+	encoder.Close()
+	return strings.TrimSuffix(b.String(), "\n"), nil
+}
@@ -0,0 +1,53 @@
+package generator
+
+import (
+	"context"
+	"outline-hexo-connector/internal/config"
+	"time"
+)
+
+// TimeLayout is how post dates are handed to the generators, in local time.
+const TimeLayout = "2006-01-02T15:04:05.000"
+
+type Post struct {
+	ID         string
+	Name       string // File name without extension, the document ID or a slug
+	Title      string
+	Date       string
+	Updated    string
+	Categories []string // Category path, outermost first
+	Tags       []string
+	BannerImg  string
+	IndexImg   string
+	Content    string
+	Math       bool
+	Mermaid    bool
+	Archive    bool
+	// FrontMatter are extra fields set in the document, merged over the generated ones
+	FrontMatter *FrontMatter
+}
+
+// Generator is a static site generator the posts are written for.
+type Generator interface {
+	// CreatePost writes post into the site sources, replacing an existing one with the same name.
+	CreatePost(post *Post) error
+	// RenderPost returns the file CreatePost would write for post, without writing it.
+	RenderPost(post *Post) (string, error)
+	// RemovePost removes the post called name from the site sources.
+	RemovePost(name string) error
+	// PostExists reports whether a post called name is in the site sources.
+	PostExists(name string) bool
+	// ListPosts returns the names of all posts in the site sources with their last updated time.
+	ListPosts() (map[string]time.Time, error)
+	// TriggerBuild requests a debounced build.
+	TriggerBuild()
+	// Build runs the build command right away.
+	Build() error
+	// SetBeforeBuild sets a function run before every build.
+	SetBeforeBuild(fn func() error)
+	// Watch starts handling build triggers until ctx is done.
+	Watch(ctx context.Context)
+	// Reconfigure checks the settings of a reloaded config and returns a function switching
+	// to them, keeping pending builds. Nothing changes until apply is called.
+	Reconfigure(cfg *config.Config) (apply func(), err error)
+}
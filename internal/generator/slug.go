@@ -0,0 +1,59 @@
+package generator
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/mozillazg/go-pinyin"
+	"golang.org/x/text/unicode/norm"
+)
+
+const maxSlugLength = 80
+
+// Letters that do not decompose into an ASCII letter and a combining mark
+var foldReplacer = strings.NewReplacer(
+	"ß", "ss", "æ", "ae", "Æ", "ae", "œ", "oe", "Œ", "oe",
+	"ø", "o", "Ø", "o", "đ", "d", "Đ", "d", "ł", "l", "Ł", "l", "þ", "th", "Þ", "th",
+)
+
+var pinyinArgs = pinyin.NewArgs()
+
+// Slugify turns s into a lowercase ASCII slug with words joined by dashes.
+// Chinese characters are transliterated to pinyin and accented Latin letters are
+// folded to ASCII, anything else that is not a letter or digit separates words.
+func Slugify(s string) string {
+	var words []string
+	var word strings.Builder
+	flush := func() {
+		if word.Len() > 0 {
+			words = append(words, word.String())
+			word.Reset()
+		}
+	}
+
+	for _, r := range norm.NFKD.String(foldReplacer.Replace(s)) {
+		switch {
+		case unicode.Is(unicode.Han, r):
+			flush()
+			if syllables := pinyin.SinglePinyin(r, pinyinArgs); len(syllables) > 0 {
+				words = append(words, syllables[0])
+			}
+		case unicode.Is(unicode.Mn, r):
+			// Combining marks split off by NFKD, e.g. the accent of é
+		case r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+			word.WriteRune(unicode.ToLower(r))
+		default:
+			flush()
+		}
+	}
+	flush()
+
+	slug := strings.Join(words, "-")
+	if len(slug) > maxSlugLength {
+		slug = slug[:maxSlugLength]
+		if i := strings.LastIndexByte(slug, '-'); i > 0 {
+			slug = slug[:i]
+		}
+	}
+	return slug
+}
@@ -0,0 +1,144 @@
+package generator
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// Trigger runs a build command, debouncing triggers that arrive within interval of the last build.
+type Trigger struct {
+	name            string
+	mu              sync.Mutex
+	command         string
+	interval        time.Duration
+	timer           *time.Timer
+	timerCh         <-chan time.Time
+	triggerCh       chan struct{}
+	lastTriggerTime time.Time
+	pending         bool
+	beforeBuild     func() error
+}
+
+// TriggerName names the build of a site in the logs, the generator alone for an unnamed site.
+func TriggerName(generator string, site string) string {
+	if site == "" {
+		return generator
+	}
+	return generator + " " + site
+}
+
+func NewTrigger(name string, command string, intervalSeconds int) *Trigger {
+	return &Trigger{
+		name:      name,
+		command:   command,
+		interval:  time.Duration(intervalSeconds) * time.Second,
+		triggerCh: make(chan struct{}, 1),
+	}
+}
+
+func (t *Trigger) buildInterval() time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.interval
+}
+
+// Reconfigure changes the build command and interval from the next build on. A pending
+// build stays pending and runs when the current interval is over.
+func (t *Trigger) Reconfigure(command string, intervalSeconds int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.command = command
+	t.interval = time.Duration(intervalSeconds) * time.Second
+}
+
+func (t *Trigger) Watch(ctx context.Context) {
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				if t.timer != nil {
+					t.timer.Stop()
+				}
+				log.Printf("Stop watching for %s build triggers", t.name)
+				return
+
+			case <-t.triggerCh:
+				if t.timer == nil {
+					log.Printf("Trigger received - Starting %s build", t.name)
+					err := t.Build()
+					if err != nil {
+						log.Printf("Error building %s - %v", t.name, err)
+					} else {
+						log.Printf("%s build completed", t.name)
+					}
+
+					t.timer = time.NewTimer(t.buildInterval())
+					t.timerCh = t.timer.C
+					t.lastTriggerTime = time.Now()
+					t.pending = false
+
+				} else {
+					t.pending = true
+					remaining := time.Until(t.lastTriggerTime.Add(t.buildInterval()))
+					log.Printf("Trigger pending - Will build after %v", remaining)
+				}
+
+			case <-t.timerCh:
+				if t.pending {
+					log.Printf("Trigger timer expired with pending tasks - Starting %s build", t.name)
+					err := t.Build()
+					if err != nil {
+						log.Printf("Error building %s - %v", t.name, err)
+					} else {
+						log.Printf("%s build completed", t.name)
+					}
+
+					t.timer.Reset(t.buildInterval())
+					t.lastTriggerTime = time.Now()
+					t.pending = false
+				} else {
+					log.Printf("Trigger timer expired with no pending tasks - Back to idle")
+					t.timer = nil
+					t.timerCh = nil
+				}
+			}
+		}
+	}()
+}
+
+func (t *Trigger) TriggerBuild() {
+	select {
+	case t.triggerCh <- struct{}{}:
+	default:
+		remaining := time.Until(t.lastTriggerTime.Add(t.buildInterval()))
+		log.Printf("Trigger pending - Will build after %v", remaining)
+	}
+}
+
+// SetBeforeBuild sets a function run before every build, e.g. to generate extra sources.
+// It must be set before Watch is called.
+func (t *Trigger) SetBeforeBuild(fn func() error) {
+	t.beforeBuild = fn
+}
+
+func (t *Trigger) Build() error {
+	if t.beforeBuild != nil {
+		err := t.beforeBuild()
+		if err != nil {
+			log.Printf("Error preparing %s build - %v", t.name, err)
+		}
+	}
+	t.mu.Lock()
+	command := t.command
+	t.mu.Unlock()
+	cmd := exec.Command("bash", "-c", command)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%w - %s", err, output)
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package hexo
+
+import (
+	"outline-hexo-connector/internal/config"
+	"outline-hexo-connector/internal/generator"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// Generator writes posts into a Hexo source/_posts directory.
+type Generator struct {
+	*generator.Trigger
+	mu       sync.RWMutex
+	postDir  string
+	template *template.Template
+}
+
+func NewGenerator(cfg *config.Config) (*Generator, error) {
+	tmpl, err := LoadPostTemplate(cfg.HexoPostTemplate)
+	if err != nil {
+		return nil, err
+	}
+	return &Generator{
+		Trigger:  generator.NewTrigger(generator.TriggerName("Hexo", cfg.Name), cfg.HexoBuildCommand, cfg.HexoBuildInterval),
+		postDir:  cfg.HexoSourcePostDir,
+		template: tmpl,
+	}, nil
+}
+
+func (g *Generator) Reconfigure(cfg *config.Config) (func(), error) {
+	tmpl, err := LoadPostTemplate(cfg.HexoPostTemplate)
+	if err != nil {
+		return nil, err
+	}
+	return func() {
+		g.mu.Lock()
+		g.postDir = cfg.HexoSourcePostDir
+		g.template = tmpl
+		g.mu.Unlock()
+		g.Trigger.Reconfigure(cfg.HexoBuildCommand, cfg.HexoBuildInterval)
+	}, nil
+}
+
+func (g *Generator) settings() (string, *template.Template) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.postDir, g.template
+}
+
+func (g *Generator) CreatePost(post *generator.Post) error {
+	postDir, tmpl := g.settings()
+	return CreateHexoPost(postDir, tmpl, post)
+}
+
+func (g *Generator) RenderPost(post *generator.Post) (string, error) {
+	_, tmpl := g.settings()
+	return renderPost(tmpl, post)
+}
+
+func (g *Generator) RemovePost(name string) error {
+	postDir, _ := g.settings()
+	return RemoveHexoPost(postDir, name)
+}
+
+func (g *Generator) PostExists(name string) bool {
+	postDir, _ := g.settings()
+	return HexoPostExists(postDir, name)
+}
+
+func (g *Generator) ListPosts() (map[string]time.Time, error) {
+	postDir, _ := g.settings()
+	return ListHexoPosts(postDir)
+}
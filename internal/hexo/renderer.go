@@ -4,101 +4,14 @@ import (
 	"bytes"
 	"log"
 	"os"
+	"outline-hexo-connector/internal/generator"
 	"path/filepath"
-	"regexp"
 	"strings"
 	"text/template"
+	"time"
 )
 
-type Post struct {
-	ID        string
-	Title     string
-	Date      string
-	Updated   string
-	Category  string
-	Tags      []string
-	BannerImg string
-	IndexImg  string
-	Content   string
-	Math      bool
-	Mermaid   bool
-	Archive   bool
-}
-
-const postTemplate = `---
-title: {{.Title}}
-date: {{.Date}}
-updated: {{.Updated}}
-categories:
-  - {{.Category}}
-tags:
-{{- range .Tags}}
-  - {{.}}
-{{- end}}
-banner_img: {{.BannerImg}}
-index_img: {{.IndexImg}}
-math: true
-mermaid: true
-archive: {{.Archive}}
----
-
-{{.Content}}
-`
-
-var (
-	codeBlockRe = regexp.MustCompile(`(?s)(~{3,}.*?~{3,}|\x60{3,}.*?\x60{3,}|\x60.*?\x60)`)
-	newlineRe   = regexp.MustCompile(`([^\x00]|^)(\\n|\\\n)`)
-)
-
-func unescapeText(text string) string {
-	// Outline sends some escaped characters in the text, we need to unescape them before processing.
-	// However, characters in Markdown code blocks should be treated as is.
-	lastEnd := 0
-	var result strings.Builder
-	matches := codeBlockRe.FindAllStringIndex(text, -1)
-
-	for _, match := range matches {
-		start, end := match[0], match[1]
-		outsideText := text[lastEnd:start]
-		result.WriteString(renderNewline(outsideText))
-		result.WriteString(text[start:end])
-		lastEnd = end
-	}
-
-	result.WriteString(renderNewline(text[lastEnd:]))
-	return result.String()
-}
-
-func renderNewline(text string) string {
-	text = strings.ReplaceAll(text, "\\\\", "\x00")
-
-	re := regexp.MustCompile(`([^\x00]|^)(\\n|\\\n)`)
-	text = re.ReplaceAllString(text, "$1\n")
-
-	text = strings.ReplaceAll(text, "\x00", "\\\\")
-	return text
-}
-
-func renderPost(post *Post) (string, error) {
-	post.Content = unescapeText(post.Content)
-
-	originalLines := strings.Split(post.Content, "\n")
-	var Lines []string
-	for i := 0; i < len(originalLines); i++ {
-		if strings.TrimSpace(originalLines[i]) == "[REMOVED]" {
-			if i+1 < len(originalLines) && strings.TrimSpace(originalLines[i+1]) == "" {
-				i++
-			}
-			continue
-		}
-		Lines = append(Lines, originalLines[i])
-	}
-	post.Content = strings.Join(Lines, "\n")
-
-	tmpl, err := template.New("post").Parse(postTemplate)
-	if err != nil {
-		return "", err
-	}
+func renderPost(tmpl *template.Template, post *generator.Post) (string, error) {
 	var buf bytes.Buffer
 	if err := tmpl.Execute(&buf, post); err != nil {
 		return "", err
@@ -106,12 +19,12 @@ func renderPost(post *Post) (string, error) {
 	return buf.String(), nil
 }
 
-func CreateHexoPost(dir string, post *Post) error {
-	content, err := renderPost(post)
+func CreateHexoPost(dir string, tmpl *template.Template, post *generator.Post) error {
+	content, err := renderPost(tmpl, post)
 	if err != nil {
 		return err
 	}
-	filePath := filepath.Join(dir, post.ID+".md")
+	filePath := filepath.Join(dir, post.Name+".md")
 	err = os.WriteFile(filePath, []byte(content), 0644)
 	if err != nil {
 		return err
@@ -120,8 +33,8 @@ func CreateHexoPost(dir string, post *Post) error {
 	return nil
 }
 
-func RemoveHexoPost(dir string, ID string) error {
-	filePath := filepath.Join(dir, ID+".md")
+func RemoveHexoPost(dir string, name string) error {
+	filePath := filepath.Join(dir, name+".md")
 	err := os.Remove(filePath)
 	if err != nil {
 		return err
@@ -129,3 +42,49 @@ func RemoveHexoPost(dir string, ID string) error {
 	log.Printf("Hexo post removed at %s", filePath)
 	return nil
 }
+
+func HexoPostExists(dir string, name string) bool {
+	_, err := os.Stat(filepath.Join(dir, name+".md"))
+	return err == nil
+}
+
+// ListHexoPosts returns the Markdown posts in dir, mapped from file name without
+// extension to the updated time found in their front matter.
+func ListHexoPosts(dir string) (map[string]time.Time, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	posts := make(map[string]time.Time)
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".md" {
+			continue
+		}
+		content, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		// A post without a readable updated time is left as zero, so it counts as outdated
+		updated, _ := time.ParseInLocation(generator.TimeLayout, readFrontMatterField(string(content), "updated"), time.Local)
+		posts[strings.TrimSuffix(entry.Name(), ".md")] = updated
+	}
+	return posts, nil
+}
+
+func readFrontMatterField(content string, key string) string {
+	lines := strings.Split(content, "\n")
+	if len(lines) == 0 || strings.TrimSpace(lines[0]) != "---" {
+		return ""
+	}
+	for _, line := range lines[1:] {
+		if strings.TrimSpace(line) == "---" {
+			break
+		}
+		k, v, found := strings.Cut(line, ":")
+		if found && k == key {
+			return strings.Trim(strings.TrimSpace(v), `"'`)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,140 @@
+package hexo
+
+import (
+	_ "embed"
+	"os"
+	"outline-hexo-connector/internal/generator"
+	"strings"
+	"text/template"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed post.tmpl
+var defaultPostTemplate string
+
+var templateFuncs = template.FuncMap{
+	"frontMatter": renderFrontMatter,
+	"field":       field,
+	"extraFields": extraFields,
+	"toYaml":      toYaml,
+	"slugify":     generator.Slugify,
+	"date":        formatDate,
+}
+
+// fluidKeys are the front matter fields newFrontMatter generates.
+var fluidKeys = []string{"title", "slug", "date", "updated", "categories", "tags", "banner_img", "index_img", "math", "mermaid", "archive"}
+
+// newFrontMatter builds the front matter fields of the Fluid theme for post.
+func newFrontMatter(post *generator.Post) *generator.FrontMatter {
+	tags := post.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	categories := post.Categories
+	if categories == nil {
+		categories = []string{}
+	}
+
+	fm := generator.NewFrontMatter()
+	fm.Set("title", post.Title)
+	if post.Name != post.ID {
+		fm.Set("slug", post.Name)
+	}
+	fm.Set("date", post.Date)
+	fm.Set("updated", post.Updated)
+	fm.Set("categories", categories)
+	fm.Set("tags", tags)
+	fm.Set("banner_img", post.BannerImg)
+	fm.Set("index_img", post.IndexImg)
+	fm.Set("math", post.Math)
+	fm.Set("mermaid", post.Mermaid)
+	fm.Set("archive", post.Archive)
+	fm.Merge(post.FrontMatter)
+	return fm
+}
+
+func renderFrontMatter(post *generator.Post) (string, error) {
+	return newFrontMatter(post).YAML()
+}
+
+// field returns the value of a Fluid front matter field, the one set in the document if
+// there is one, e.g. title: {{ toYaml (field . "title") }}.
+func field(post *generator.Post, key string) any {
+	value, _ := newFrontMatter(post).Get(key)
+	return value
+}
+
+// extraFields returns the fields set in the document besides the Fluid ones as YAML.
+func extraFields(post *generator.Post) (string, error) {
+	if post.FrontMatter == nil {
+		return "", nil
+	}
+	fluid := make(map[string]bool, len(fluidKeys))
+	for _, key := range fluidKeys {
+		fluid[key] = true
+	}
+	extra := generator.NewFrontMatter()
+	for _, key := range post.FrontMatter.Keys() {
+		if !fluid[key] {
+			value, _ := post.FrontMatter.Get(key)
+			extra.Set(key, value)
+		}
+	}
+	if len(extra.Keys()) == 0 {
+		return "", nil
+	}
+	return extra.YAML()
+}
+
+// toYaml serializes a value on a single line, so it can follow a key, e.g. tags: {{ toYaml .Tags }}.
+func toYaml(v any) (string, error) {
+	var node yaml.Node
+	if err := node.Encode(v); err != nil {
+		return "", err
+	}
+	inlineNode(&node)
+	out, err := yaml.Marshal(&node)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(string(out), "\n"), nil
+}
+
+// inlineNode switches collections to flow style and multi-line strings to double quotes.
+func inlineNode(node *yaml.Node) {
+	switch node.Kind {
+	case yaml.SequenceNode, yaml.MappingNode:
+		node.Style = yaml.FlowStyle
+	case yaml.ScalarNode:
+		if strings.Contains(node.Value, "\n") {
+			node.Style = yaml.DoubleQuotedStyle
+		}
+	}
+	for _, child := range node.Content {
+		inlineNode(child)
+	}
+}
+
+// formatDate reformats a post time, e.g. {{ date "2006-01-02" .Date }}.
+func formatDate(layout string, ts string) (string, error) {
+	parsed, err := time.ParseInLocation(generator.TimeLayout, ts, time.Local)
+	if err != nil {
+		return "", err
+	}
+	return parsed.Format(layout), nil
+}
+
+// LoadPostTemplate parses the post template at path, or the built-in one if path is empty.
+func LoadPostTemplate(path string) (*template.Template, error) {
+	text := defaultPostTemplate
+	if path != "" {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		text = string(content)
+	}
+	return template.New("post").Funcs(templateFuncs).Parse(text)
+}
@@ -0,0 +1,206 @@
+package hexo
+
+import (
+	"outline-hexo-connector/internal/generator"
+	"reflect"
+	"strings"
+	"testing"
+	"text/template"
+
+	"gopkg.in/yaml.v3"
+)
+
+var hostileStrings = []string{
+	`Say "hello" and 'bye'`,
+	`Go: the good parts`,
+	`C# #hashtag`,
+	`- leading dash`,
+	`* leading star`,
+	"line one\nline two",
+	`no`,
+	`yes`,
+	`true`,
+	`null`,
+	`~`,
+	`1.0`,
+	`[not, a, list]`,
+	`{not: a map}`,
+	`&anchor`,
+	`*alias`,
+	`!tag`,
+	`% percent`,
+	`@at`,
+	"`backtick`",
+	`| pipe`,
+	`> folded`,
+	`trailing space `,
+	`---`,
+	`中文 标题`,
+	``,
+}
+
+type parsedFrontMatter struct {
+	Title      string   `yaml:"title"`
+	Slug       string   `yaml:"slug"`
+	Date       string   `yaml:"date"`
+	Categories []string `yaml:"categories"`
+	Tags       []string `yaml:"tags"`
+	BannerImg  string   `yaml:"banner_img"`
+	IndexImg   string   `yaml:"index_img"`
+	Math       bool     `yaml:"math"`
+	Archive    bool     `yaml:"archive"`
+}
+
+// parseFrontMatter parses the front matter between the leading --- lines of content.
+func parseFrontMatter(t *testing.T, content string) parsedFrontMatter {
+	t.Helper()
+	rest, ok := strings.CutPrefix(content, "---\n")
+	if !ok {
+		t.Fatalf("no front matter in %q", content)
+	}
+	header, _, ok := strings.Cut(rest, "\n---\n")
+	if !ok {
+		t.Fatalf("unterminated front matter in %q", content)
+	}
+	var fm parsedFrontMatter
+	if err := yaml.Unmarshal([]byte(header), &fm); err != nil {
+		t.Fatalf("front matter is not valid YAML - %v\n%s", err, header)
+	}
+	return fm
+}
+
+func TestFrontMatterRoundTrip(t *testing.T) {
+	templates := map[string]string{
+		"builtin": "",
+		"toYaml": "---\ntitle: {{ toYaml .Title }}\nslug: {{ toYaml .Name }}\ndate: {{ toYaml .Date }}\n" +
+			"categories: {{ toYaml .Categories }}\ntags: {{ toYaml .Tags }}\nbanner_img: {{ toYaml .BannerImg }}\n" +
+			"index_img: {{ toYaml .IndexImg }}\nmath: {{ toYaml .Math }}\narchive: {{ toYaml .Archive }}\n---\n\n{{ .Content }}",
+	}
+
+	for name, text := range templates {
+		tmpl := template.Must(template.New("post").Funcs(templateFuncs).Parse(text))
+		if text == "" {
+			var err error
+			if tmpl, err = LoadPostTemplate(""); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		for _, value := range hostileStrings {
+			post := &generator.Post{
+				ID:         "doc",
+				Name:       "a-slug",
+				Title:      value,
+				Date:       "2024-01-02T03:04:05.000",
+				Categories: []string{value, "Plain"},
+				Tags:       []string{value},
+				BannerImg:  value,
+				IndexImg:   value,
+				Math:       true,
+				Content:    "Body",
+			}
+			content, err := renderPost(tmpl, post)
+			if err != nil {
+				t.Fatalf("%s %q - %v", name, value, err)
+			}
+
+			got := parseFrontMatter(t, content)
+			want := parsedFrontMatter{
+				Title:      value,
+				Slug:       "a-slug",
+				Date:       "2024-01-02T03:04:05.000",
+				Categories: []string{value, "Plain"},
+				Tags:       []string{value},
+				BannerImg:  value,
+				IndexImg:   value,
+				Math:       true,
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("%s %q\n got %+v\nwant %+v\n%s", name, value, got, want, content)
+			}
+		}
+	}
+}
+
+func TestFrontMatterMerge(t *testing.T) {
+	extra := generator.NewFrontMatter()
+	extra.Set("title", "no")
+	extra.Set("excerpt", "Key: value # not a comment")
+
+	fm := newFrontMatter(&generator.Post{ID: "doc", Name: "doc", Title: "Original", FrontMatter: extra})
+	out, err := fm.YAML()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got map[string]any
+	if err := yaml.Unmarshal([]byte(out), &got); err != nil {
+		t.Fatalf("%v\n%s", err, out)
+	}
+	if got["title"] != "no" || got["excerpt"] != "Key: value # not a comment" {
+		t.Errorf("merged fields = %v / %v\n%s", got["title"], got["excerpt"], out)
+	}
+	if _, ok := got["slug"]; ok {
+		t.Errorf("slug set for a post named after its ID\n%s", out)
+	}
+}
+
+func TestPostTemplateExtraFields(t *testing.T) {
+	tmpl, err := LoadPostTemplate("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	extra := generator.NewFrontMatter()
+	extra.Set("title", "Overridden")
+	extra.Set("index_img", "/img/cover.png")
+	extra.Set("excerpt", "Key: value # not a comment")
+	extra.Set("sticky", 10)
+
+	tests := []struct {
+		name     string
+		post     *generator.Post
+		want     map[string]any
+		wantSlug bool
+	}{
+		{
+			name:     "generated fields",
+			post:     &generator.Post{ID: "doc", Name: "a-slug", Title: "Original", Content: "Body"},
+			want:     map[string]any{"title": "Original", "slug": "a-slug", "index_img": ""},
+			wantSlug: true,
+		},
+		{
+			name: "merged fields",
+			post: &generator.Post{ID: "doc", Name: "doc", Title: "Original", Content: "Body", FrontMatter: extra},
+			want: map[string]any{"title": "Overridden", "index_img": "/img/cover.png", "excerpt": "Key: value # not a comment", "sticky": 10},
+		},
+	}
+
+	for _, tt := range tests {
+		content, err := renderPost(tmpl, tt.post)
+		if err != nil {
+			t.Fatalf("%s - %v", tt.name, err)
+		}
+		header, _, _ := strings.Cut(strings.TrimPrefix(content, "---\n"), "\n---\n")
+		// yaml.v3 rejects duplicate keys, so a field is written once.
+		var got map[string]any
+		if err := yaml.Unmarshal([]byte(header), &got); err != nil {
+			t.Fatalf("%s - %v\n%s", tt.name, err, content)
+		}
+		for key, want := range tt.want {
+			if got[key] != want {
+				t.Errorf("%s %s\n got %v\nwant %v\n%s", tt.name, key, got[key], want, content)
+			}
+		}
+		for _, key := range fluidKeys {
+			if _, ok := got[key]; !ok && (key != "slug" || tt.wantSlug) {
+				t.Errorf("%s: %s missing\n%s", tt.name, key, content)
+			}
+		}
+		if _, ok := got["slug"]; ok && !tt.wantSlug {
+			t.Errorf("%s: slug set for a post named after its ID\n%s", tt.name, content)
+		}
+		if !strings.HasSuffix(content, "\n---\n\nBody\n") {
+			t.Errorf("%s: body not after the front matter\n%s", tt.name, content)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package hugo
+
+import (
+	"outline-hexo-connector/internal/config"
+	"outline-hexo-connector/internal/generator"
+	"sync"
+	"time"
+)
+
+// Generator writes posts as page bundles into a Hugo content section.
+type Generator struct {
+	*generator.Trigger
+	mu                sync.RWMutex
+	contentDir        string
+	frontMatterFormat string
+}
+
+func NewGenerator(cfg *config.Config) *Generator {
+	return &Generator{
+		Trigger:           generator.NewTrigger(generator.TriggerName("Hugo", cfg.Name), cfg.HugoBuildCommand, cfg.HugoBuildInterval),
+		contentDir:        cfg.HugoContentDir,
+		frontMatterFormat: cfg.HugoFrontMatterFormat,
+	}
+}
+
+func (g *Generator) Reconfigure(cfg *config.Config) (func(), error) {
+	if _, err := renderPost(&generator.Post{}, cfg.HugoFrontMatterFormat); err != nil {
+		return nil, err
+	}
+	return func() {
+		g.mu.Lock()
+		g.contentDir = cfg.HugoContentDir
+		g.frontMatterFormat = cfg.HugoFrontMatterFormat
+		g.mu.Unlock()
+		g.Trigger.Reconfigure(cfg.HugoBuildCommand, cfg.HugoBuildInterval)
+	}, nil
+}
+
+func (g *Generator) settings() (string, string) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.contentDir, g.frontMatterFormat
+}
+
+func (g *Generator) CreatePost(post *generator.Post) error {
+	contentDir, frontMatterFormat := g.settings()
+	return CreateHugoPost(contentDir, post, frontMatterFormat)
+}
+
+func (g *Generator) RenderPost(post *generator.Post) (string, error) {
+	_, frontMatterFormat := g.settings()
+	return renderPost(post, frontMatterFormat)
+}
+
+func (g *Generator) RemovePost(name string) error {
+	contentDir, _ := g.settings()
+	return RemoveHugoPost(contentDir, name)
+}
+
+func (g *Generator) PostExists(name string) bool {
+	contentDir, _ := g.settings()
+	return HugoPostExists(contentDir, name)
+}
+
+func (g *Generator) ListPosts() (map[string]time.Time, error) {
+	contentDir, _ := g.settings()
+	return ListHugoPosts(contentDir)
+}
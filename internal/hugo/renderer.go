@@ -0,0 +1,311 @@
+package hugo
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"outline-hexo-connector/internal/generator"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	FrontMatterYAML = "yaml"
+	FrontMatterTOML = "toml"
+)
+
+// formatTime converts a generator.TimeLayout time into RFC 3339, which Hugo expects.
+func formatTime(ts string) string {
+	parsed, err := time.ParseInLocation(generator.TimeLayout, ts, time.Local)
+	if err != nil {
+		return ts
+	}
+	return parsed.Format(time.RFC3339)
+}
+
+// newFrontMatter builds the front matter fields of a Hugo page for post.
+func newFrontMatter(post *generator.Post) *generator.FrontMatter {
+	categories := post.Categories
+	if categories == nil {
+		categories = []string{}
+	}
+	tags := post.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	var images []string
+	for _, img := range []string{post.BannerImg, post.IndexImg} {
+		if img != "" && (len(images) == 0 || images[0] != img) {
+			images = append(images, img)
+		}
+	}
+
+	fm := generator.NewFrontMatter()
+	fm.Set("title", post.Title)
+	if post.Name != post.ID {
+		fm.Set("slug", post.Name)
+	}
+	fm.Set("date", formatTime(post.Date))
+	fm.Set("lastmod", formatTime(post.Updated))
+	fm.Set("categories", categories)
+	fm.Set("tags", tags)
+	fm.Set("draft", false)
+	if len(images) > 0 {
+		fm.Set("images", images)
+	}
+	fm.Merge(post.FrontMatter)
+	return fm
+}
+
+func tomlString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				fmt.Fprintf(&b, `\u%04X`, r)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+func tomlValue(v any) (string, error) {
+	switch v := v.(type) {
+	case string:
+		return tomlString(v), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int:
+		return strconv.Itoa(v), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case uint64:
+		return strconv.FormatUint(v, 10), nil
+	case float64:
+		s := strconv.FormatFloat(v, 'f', -1, 64)
+		if !strings.ContainsAny(s, ".eE") {
+			s += ".0"
+		}
+		return s, nil
+	case time.Time:
+		return v.Format(time.RFC3339), nil
+	case []string:
+		quoted := make([]string, len(v))
+		for i, item := range v {
+			quoted[i] = tomlString(item)
+		}
+		return "[" + strings.Join(quoted, ", ") + "]", nil
+	case []any:
+		items := make([]string, len(v))
+		for i, item := range v {
+			value, err := tomlValue(item)
+			if err != nil {
+				return "", err
+			}
+			items[i] = value
+		}
+		return "[" + strings.Join(items, ", ") + "]", nil
+	case map[string]any:
+		// Inline tables, so nested fields stay on the line of their key
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		items := make([]string, len(keys))
+		for i, key := range keys {
+			value, err := tomlValue(v[key])
+			if err != nil {
+				return "", fmt.Errorf("%s - %w", key, err)
+			}
+			items[i] = tomlKey(key) + " = " + value
+		}
+		return "{" + strings.Join(items, ", ") + "}", nil
+	}
+	return "", fmt.Errorf("Unsupported TOML value of type %T", v)
+}
+
+func toml(fm *generator.FrontMatter) string {
+	var b strings.Builder
+	for _, key := range fm.Keys() {
+		value, _ := fm.Get(key)
+		encoded, err := tomlValue(value)
+		if err != nil {
+			log.Printf("Error writing front matter field %s - %v - Skipping", key, err)
+			continue
+		}
+		fmt.Fprintf(&b, "%s = %s\n", tomlKey(key), encoded)
+	}
+	return b.String()
+}
+
+var bareTomlKeyRe = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+func tomlKey(key string) string {
+	if bareTomlKeyRe.MatchString(key) {
+		return key
+	}
+	return tomlString(key)
+}
+
+func renderPost(post *generator.Post, format string) (string, error) {
+	fm := newFrontMatter(post)
+
+	var buf bytes.Buffer
+	switch format {
+	case FrontMatterTOML:
+		buf.WriteString("+++\n")
+		buf.WriteString(toml(fm))
+		buf.WriteString("+++\n")
+	case FrontMatterYAML, "":
+		yamlText, err := fm.YAML()
+		if err != nil {
+			return "", err
+		}
+		buf.WriteString("---\n")
+		buf.WriteString(yamlText)
+		buf.WriteString("\n---\n")
+	default:
+		return "", fmt.Errorf("Unknown front matter format - %s", format)
+	}
+	buf.WriteString("\n")
+	buf.WriteString(post.Content)
+	buf.WriteString("\n")
+	return buf.String(), nil
+}
+
+// checkName makes sure name is a single directory below the content dir, so no other
+// directory is written or removed as a page bundle.
+func checkName(name string) error {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return fmt.Errorf("Invalid Hugo post name %q", name)
+	}
+	return nil
+}
+
+// CreateHugoPost writes post as a page bundle at <dir>/<name>/index.md.
+func CreateHugoPost(dir string, post *generator.Post, format string) error {
+	if err := checkName(post.Name); err != nil {
+		return err
+	}
+	content, err := renderPost(post, format)
+	if err != nil {
+		return err
+	}
+	bundleDir := filepath.Join(dir, post.Name)
+	err = os.MkdirAll(bundleDir, 0755)
+	if err != nil {
+		return err
+	}
+	filePath := filepath.Join(bundleDir, "index.md")
+	err = os.WriteFile(filePath, []byte(content), 0644)
+	if err != nil {
+		return err
+	}
+	log.Printf("Hugo post created at %s", filePath)
+	return nil
+}
+
+func RemoveHugoPost(dir string, name string) error {
+	if err := checkName(name); err != nil {
+		return err
+	}
+	bundleDir := filepath.Join(dir, name)
+	if _, err := os.Stat(bundleDir); err != nil {
+		return err
+	}
+	err := os.RemoveAll(bundleDir)
+	if err != nil {
+		return err
+	}
+	log.Printf("Hugo post removed at %s", bundleDir)
+	return nil
+}
+
+// HugoPostExists reports whether dir holds a page bundle called name, the way ListHugoPosts lists them.
+func HugoPostExists(dir string, name string) bool {
+	_, err := os.Stat(filepath.Join(dir, name, "index.md"))
+	return err == nil
+}
+
+// ListHugoPosts returns the page bundles in dir, mapped from bundle name to the
+// lastmod time found in their front matter.
+func ListHugoPosts(dir string) (map[string]time.Time, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	posts := make(map[string]time.Time)
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		lastmod, err := readLastmod(filepath.Join(dir, entry.Name(), "index.md"))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		posts[entry.Name()] = lastmod
+	}
+	return posts, nil
+}
+
+func readLastmod(path string) (time.Time, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	if !scanner.Scan() {
+		return time.Time{}, scanner.Err()
+	}
+	delimiter := strings.TrimSpace(scanner.Text())
+	if delimiter != "---" && delimiter != "+++" {
+		return time.Time{}, nil
+	}
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == delimiter {
+			break
+		}
+		key, value, found := strings.Cut(line, "=")
+		if delimiter == "---" {
+			key, value, found = strings.Cut(line, ":")
+		}
+		if !found || strings.TrimSpace(key) != "lastmod" {
+			continue
+		}
+		value = strings.Trim(strings.TrimSpace(value), `"'`)
+		// A post without a readable lastmod is left as zero, so it counts as outdated
+		lastmod, _ := time.Parse(time.RFC3339, value)
+		return lastmod, nil
+	}
+	return time.Time{}, scanner.Err()
+}
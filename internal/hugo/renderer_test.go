@@ -0,0 +1,149 @@
+package hugo
+
+import (
+	"errors"
+	"os"
+	"outline-hexo-connector/internal/generator"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+func testPost() *generator.Post {
+	extra := generator.NewFrontMatter()
+	extra.Set("weight", 10)
+	extra.Set("params", map[string]any{"toc": true, "series": "Go", "sub title": "A \"quoted\" one"})
+	extra.Set("aliases", []any{"/old/", "/older/"})
+	return &generator.Post{
+		ID:          "doc",
+		Name:        "hello-world",
+		Title:       `Say "hello"`,
+		Date:        "2024-01-02 03:04:05",
+		Updated:     "2024-02-03 04:05:06",
+		Categories:  []string{"Go", "Web"},
+		Tags:        []string{"tag"},
+		BannerImg:   "/images/banner.png",
+		Content:     "Hello world",
+		FrontMatter: extra,
+	}
+}
+
+func TestRenderTOML(t *testing.T) {
+	content, err := renderPost(testPost(), FrontMatterTOML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	date := formatTime("2024-01-02 03:04:05")
+	lastmod := formatTime("2024-02-03 04:05:06")
+	want := "+++\n" +
+		`title = "Say \"hello\""` + "\n" +
+		`slug = "hello-world"` + "\n" +
+		`date = "` + date + `"` + "\n" +
+		`lastmod = "` + lastmod + `"` + "\n" +
+		`categories = ["Go", "Web"]` + "\n" +
+		`tags = ["tag"]` + "\n" +
+		"draft = false\n" +
+		`images = ["/images/banner.png"]` + "\n" +
+		"weight = 10\n" +
+		`params = {series = "Go", "sub title" = "A \"quoted\" one", toc = true}` + "\n" +
+		`aliases = ["/old/", "/older/"]` + "\n" +
+		"+++\n\nHello world\n"
+	if content != want {
+		t.Errorf("\n got %q\nwant %q", content, want)
+	}
+}
+
+func TestRenderYAML(t *testing.T) {
+	content, err := renderPost(testPost(), FrontMatterYAML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rest, ok := strings.CutPrefix(content, "---\n")
+	if !ok {
+		t.Fatalf("no front matter in %q", content)
+	}
+	fields, body, ok := strings.Cut(rest, "\n---\n")
+	if !ok || body != "\nHello world\n" {
+		t.Fatalf("front matter not closed before the content in %q", content)
+	}
+
+	var fm struct {
+		Title      string         `yaml:"title"`
+		Slug       string         `yaml:"slug"`
+		Lastmod    string         `yaml:"lastmod"`
+		Categories []string       `yaml:"categories"`
+		Images     []string       `yaml:"images"`
+		Weight     int            `yaml:"weight"`
+		Params     map[string]any `yaml:"params"`
+		Aliases    []string       `yaml:"aliases"`
+	}
+	if err := yaml.Unmarshal([]byte(fields), &fm); err != nil {
+		t.Fatalf("front matter is not valid YAML - %v\n%s", err, fields)
+	}
+	if fm.Title != `Say "hello"` || fm.Slug != "hello-world" || fm.Lastmod != formatTime("2024-02-03 04:05:06") {
+		t.Errorf("front matter = %+v", fm)
+	}
+	if len(fm.Categories) != 2 || len(fm.Images) != 1 || fm.Weight != 10 || len(fm.Aliases) != 2 {
+		t.Errorf("front matter = %+v", fm)
+	}
+	if fm.Params["toc"] != true || fm.Params["sub title"] != `A "quoted" one` {
+		t.Errorf("params = %v", fm.Params)
+	}
+}
+
+func TestCreateAndRemovePost(t *testing.T) {
+	for _, format := range []string{FrontMatterTOML, FrontMatterYAML} {
+		dir := t.TempDir()
+		post := testPost()
+		if err := CreateHugoPost(dir, post, format); err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		if !HugoPostExists(dir, post.Name) {
+			t.Errorf("%s: post not found at %s", format, filepath.Join(dir, post.Name, "index.md"))
+		}
+
+		posts, err := ListHugoPosts(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, _ := time.Parse(time.RFC3339, formatTime(post.Updated))
+		if lastmod, ok := posts[post.Name]; !ok || !lastmod.Equal(want) {
+			t.Errorf("%s: ListHugoPosts = %v, want %s at %v", format, posts, post.Name, want)
+		}
+
+		if err := RemoveHugoPost(dir, post.Name); err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		if _, err := os.Stat(filepath.Join(dir, post.Name)); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("%s: bundle still there - %v", format, err)
+		}
+		if err := RemoveHugoPost(dir, post.Name); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("%s: removing again = %v, want not exist", format, err)
+		}
+	}
+}
+
+func TestInvalidPostNames(t *testing.T) {
+	parent := t.TempDir()
+	dir := filepath.Join(parent, "posts")
+	if err := os.MkdirAll(filepath.Join(dir, "other"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"", ".", "..", "other/..", "../posts", `a\b`} {
+		if err := RemoveHugoPost(dir, name); err == nil || errors.Is(err, os.ErrNotExist) {
+			t.Errorf("RemoveHugoPost(%q) = %v, want an invalid name error", name, err)
+		}
+		post := testPost()
+		post.Name = name
+		if err := CreateHugoPost(dir, post, FrontMatterYAML); err == nil {
+			t.Errorf("CreateHugoPost(%q) succeeded", name)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "other")); err != nil {
+		t.Errorf("content dir was touched - %v", err)
+	}
+}
@@ -0,0 +1,162 @@
+package outline
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"outline-hexo-connector/internal/state"
+	"path"
+	"path/filepath"
+)
+
+// attachmentDownloader stores attachments of one document inside the site
+// and hands out site-relative URLs instead of links to the storage bucket.
+type attachmentDownloader struct {
+	client     *Client
+	documentID string
+}
+
+func (d *attachmentDownloader) GetAttachmentUrl(ctx context.Context, id string) (string, error) {
+	// Outline attachments never change, so a file we already have is reused as is
+	if siteUrl, ok := d.stored(id); ok {
+		return siteUrl, nil
+	}
+
+	c := d.client
+	dir := filepath.Join(c.config().AttachmentDir(), d.documentID)
+	location, err := c.getAttachmentLocation(ctx, id)
+	if err != nil {
+		return "", err
+	}
+	name, err := c.downloadAttachment(ctx, location, dir)
+	if err != nil {
+		return "", err
+	}
+
+	err = c.store.Update(d.documentID, func(doc *state.Document) {
+		if doc.Attachments == nil {
+			doc.Attachments = make(map[string]string)
+		}
+		doc.Attachments[id] = name
+	})
+	if err != nil {
+		log.Printf("Error saving document state - %v", err)
+	}
+	return d.siteUrl(name), nil
+}
+
+// stored returns the site URL of attachment id when it has been downloaded before.
+func (d *attachmentDownloader) stored(id string) (string, bool) {
+	doc, ok := d.client.store.Get(d.documentID)
+	if !ok {
+		return "", false
+	}
+	name, ok := doc.Attachments[id]
+	if !ok {
+		return "", false
+	}
+	if _, err := os.Stat(filepath.Join(d.client.config().AttachmentDir(), d.documentID, name)); err != nil {
+		return "", false
+	}
+	return d.siteUrl(name), true
+}
+
+func (d *attachmentDownloader) siteUrl(name string) string {
+	return path.Join(d.client.config().AttachmentURLPrefix(), d.documentID, name)
+}
+
+// removeAttachments deletes the attachments downloaded for document id.
+func (c *Client) removeAttachments(id string) error {
+	// The ID names a directory of its own, never anything above it
+	if id == "" || id != filepath.Base(id) || id == ".." {
+		return nil
+	}
+	dir := filepath.Join(c.config().AttachmentDir(), id)
+	if _, err := os.Stat(dir); errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	err := os.RemoveAll(dir)
+	if err != nil {
+		return err
+	}
+	log.Printf("Attachments removed at %s", dir)
+	return nil
+}
+
+// downloadAttachment saves location into dir, named after the hash of its content.
+func (c *Client) downloadAttachment(ctx context.Context, location string, dir string) (string, error) {
+	// Outline's local file storage redirects to a path relative to its own host
+	base, err := url.Parse(c.config().OutlineAPIURL)
+	if err != nil {
+		return "", err
+	}
+	target, err := base.Parse(location)
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", target.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	if target.Host == base.Host {
+		req.Header.Set("Authorization", "Bearer "+c.config().OutlineAPIKey)
+	}
+
+	resp, err := c.httpClientDownload.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("Unexpected attachment http status - %d", resp.StatusCode)
+	}
+
+	err = os.MkdirAll(dir, 0755)
+	if err != nil {
+		return "", err
+	}
+	tmpFile, err := os.CreateTemp(dir, ".download-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmpFile.Name())
+
+	hash := sha256.New()
+	_, err = io.Copy(io.MultiWriter(tmpFile, hash), resp.Body)
+	if closeErr := tmpFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", err
+	}
+
+	name := hex.EncodeToString(hash.Sum(nil))[:16] + attachmentExt(target, resp.Header.Get("Content-Type"))
+	err = os.Rename(tmpFile.Name(), filepath.Join(dir, name))
+	if err != nil {
+		return "", err
+	}
+	log.Printf("Attachment downloaded to %s", filepath.Join(dir, name))
+	return name, nil
+}
+
+func attachmentExt(target *url.URL, contentType string) string {
+	if ext := path.Ext(target.Path); ext != "" {
+		return ext
+	}
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+		if exts, err := mime.ExtensionsByType(mediaType); err == nil && len(exts) > 0 {
+			return exts[0]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,113 @@
+package outline
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+type cacheEntry[T any] struct {
+	value   T
+	expires time.Time
+}
+
+// cache keeps Outline metadata for ttl. Entries are dropped before that when a webhook
+// reports a change.
+type cache[T any] struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]cacheEntry[T]
+}
+
+func newCache[T any](ttl time.Duration) *cache[T] {
+	return &cache[T]{
+		ttl:     ttl,
+		entries: make(map[string]cacheEntry[T]),
+	}
+}
+
+func (c *cache[T]) get(id string) (T, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[id]
+	if !ok {
+		var zero T
+		return zero, false
+	}
+	if time.Now().After(entry.expires) {
+		delete(c.entries, id)
+		var zero T
+		return zero, false
+	}
+	return entry.value, true
+}
+
+func (c *cache[T]) set(id string, value T) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	for key, entry := range c.entries {
+		if now.After(entry.expires) {
+			delete(c.entries, key)
+		}
+	}
+	if c.ttl <= 0 {
+		// Caching is disabled
+		return
+	}
+	c.entries[id] = cacheEntry[T]{value: value, expires: now.Add(c.ttl)}
+}
+
+// setTTL changes how long entries set from now on are kept.
+func (c *cache[T]) setTTL(ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ttl = ttl
+}
+
+func (c *cache[T]) invalidate(id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, id)
+}
+
+// collectionInfo is GetCollection through the cache.
+func (c *Client) collectionInfo(ctx context.Context, id string) (CollectionPayload, error) {
+	if collection, ok := c.collections.get(id); ok {
+		return collection, nil
+	}
+	collection, err := c.GetCollection(ctx, id)
+	if err != nil {
+		return collection, err
+	}
+	c.collections.set(id, collection)
+	return collection, nil
+}
+
+// parentInfo is GetDocument through the cache, for documents only needed for their
+// place in the hierarchy. The text is not kept.
+func (c *Client) parentInfo(ctx context.Context, id string) (DocumentPayload, error) {
+	if doc, ok := c.documents.get(id); ok {
+		return doc, nil
+	}
+	doc, err := c.GetDocument(ctx, id)
+	if err != nil {
+		return doc, err
+	}
+	doc.Text = ""
+	c.documents.set(id, doc)
+	return doc, nil
+}
+
+// invalidateCache drops what webhook reports as changed.
+func (c *Client) invalidateCache(webhook *Webhook) {
+	switch {
+	case strings.HasPrefix(webhook.Event, "collections."):
+		c.collections.invalidate(webhook.Payload.Model.ID)
+	case strings.HasPrefix(webhook.Event, "documents."):
+		c.documents.invalidate(webhook.Payload.Model.ID)
+	}
+}
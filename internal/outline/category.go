@@ -0,0 +1,46 @@
+package outline
+
+import (
+	"context"
+)
+
+// isPostDocument reports whether doc is published as a post. Top-level documents
+// only hold categories, unless configured otherwise.
+func (c *Client) isPostDocument(doc *DocumentPayload) bool {
+	return doc.ParentDocumentID != "" || c.config().OutlineTopLevelAsPost
+}
+
+// categoriesOf walks up the parents of doc and returns their titles, outermost first,
+// cut to Outline_Category_Max_Depth. Parents found in known or in the cache are not
+// fetched again, fetched ones are added to known if it is not nil.
+func (c *Client) categoriesOf(ctx context.Context, doc *DocumentPayload, known map[string]*DocumentPayload) ([]string, error) {
+	var titles []string
+	seen := map[string]bool{doc.ID: true}
+	parentID := doc.ParentDocumentID
+	for parentID != "" && !seen[parentID] {
+		seen[parentID] = true
+
+		parent, ok := known[parentID]
+		if !ok {
+			parentDocument, err := c.parentInfo(ctx, parentID)
+			if err != nil {
+				return nil, err
+			}
+			parent = &parentDocument
+			if known != nil {
+				known[parentID] = parent
+			}
+		}
+		if doc.ParentDocument == nil {
+			doc.ParentDocument = parent
+		}
+
+		titles = append([]string{parent.Title}, titles...)
+		parentID = parent.ParentDocumentID
+	}
+
+	if maxDepth := c.config().OutlineCategoryMaxDepth; maxDepth > 0 && len(titles) > maxDepth {
+		titles = titles[:maxDepth]
+	}
+	return titles, nil
+}
@@ -1,35 +1,50 @@
 package outline
 
 import (
-	"bytes"
+	"context"
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"log"
 	"net/http"
+	"os"
 	"outline-hexo-connector/internal/config"
-	"outline-hexo-connector/internal/hexo"
+	"outline-hexo-connector/internal/generator"
 	"outline-hexo-connector/internal/processor"
+	"outline-hexo-connector/internal/state"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 type Client struct {
-	cfg                  *config.Config
+	cfg                  atomic.Pointer[config.Config]
 	httpClient           *http.Client
 	httpClientNoRedirect *http.Client
+	httpClientDownload   *http.Client
 	justCreatedOrUpdated sync.Map
-	hexoTrigger          *hexo.Trigger
+	recentDeliveries     sync.Map
+	generator            generator.Generator
+	pipeline             atomic.Pointer[processor.Pipeline]
+	store                *state.Store
+	collectionIDsMu      sync.Mutex
+	collectionIDs        []string
+	postNamesMu          sync.Mutex
+	reservedPostNames    map[string]string // Post name to the document it is kept for
+	collections          *cache[CollectionPayload]
+	documents            *cache[DocumentPayload]
+	rateLimitMu          sync.Mutex
+	rateLimitedUntil     time.Time
 }
 
-func NewClient(cfg *config.Config, hexoTrigger *hexo.Trigger) *Client {
-	return &Client{
-		cfg: cfg,
+func NewClient(cfg *config.Config, gen generator.Generator, pipeline *processor.Pipeline, store *state.Store) *Client {
+	c := &Client{
 		httpClient: &http.Client{
 			Timeout: time.Second * 10,
 		},
@@ -39,12 +54,56 @@ func NewClient(cfg *config.Config, hexoTrigger *hexo.Trigger) *Client {
 				return http.ErrUseLastResponse
 			},
 		},
-		hexoTrigger: hexoTrigger,
+		httpClientDownload: &http.Client{
+			Timeout: time.Minute * 2,
+		},
+		collections:       newCache[CollectionPayload](time.Duration(cfg.OutlineCacheTTL) * time.Second),
+		documents:         newCache[DocumentPayload](time.Duration(cfg.OutlineCacheTTL) * time.Second),
+		generator:         gen,
+		store:             store,
+		reservedPostNames: make(map[string]string),
+	}
+	c.cfg.Store(cfg)
+	c.pipeline.Store(pipeline)
+	return c
+}
+
+func (c *Client) config() *config.Config {
+	return c.cfg.Load()
+}
+
+// Reconfigure swaps in the settings of a reloaded config for the events that follow.
+// The collections are looked up again if they changed.
+func (c *Client) Reconfigure(cfg *config.Config, pipeline *processor.Pipeline) {
+	previous := c.cfg.Swap(cfg)
+	c.pipeline.Store(pipeline)
+
+	ttl := time.Duration(cfg.OutlineCacheTTL) * time.Second
+	c.collections.setTTL(ttl)
+	c.documents.setTTL(ttl)
+
+	if !slices.Equal(previous.CollectionNames(), cfg.CollectionNames()) || !slices.Equal(previous.OutlineCollectionIDs, cfg.OutlineCollectionIDs) {
+		c.collectionIDsMu.Lock()
+		c.collectionIDs = nil
+		c.collectionIDsMu.Unlock()
 	}
 }
 
-func (c *Client) verifyWebhook(body []byte, signatureHeader string) error {
-	if c.cfg.OutlineWebhookSecret == "" {
+// webhookSignature is the hex HMAC-SHA256 of "<t>.<body>" Outline signs webhooks with.
+func webhookSignature(secret string, t string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(t + "." + string(body)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// SignWebhook returns the Outline-Signature header of body sent at now.
+func SignWebhook(secret string, now time.Time, body []byte) string {
+	t := strconv.FormatInt(now.UnixMilli(), 10)
+	return "t=" + t + ",s=" + webhookSignature(secret, t, body)
+}
+
+func verifyWebhook(secret string, body []byte, signatureHeader string) error {
+	if secret == "" {
 		return fmt.Errorf("No webhook secret configured in config")
 	}
 
@@ -78,10 +137,7 @@ func (c *Client) verifyWebhook(body []byte, signatureHeader string) error {
 		return fmt.Errorf("Signature timestamp is in the future")
 	}
 
-	message := t + "." + string(body)
-	mac := hmac.New(sha256.New, []byte(c.cfg.OutlineWebhookSecret))
-	mac.Write([]byte(message))
-	expected := hex.EncodeToString(mac.Sum(nil))
+	expected := webhookSignature(secret, t, body)
 	if !hmac.Equal([]byte(expected), []byte(s)) {
 		return fmt.Errorf("Signature invalid")
 	}
@@ -89,7 +145,7 @@ func (c *Client) verifyWebhook(body []byte, signatureHeader string) error {
 	return nil
 }
 
-func (c *Client) parseWebhook(body []byte) (*Webhook, error) {
+func parseWebhook(body []byte) (*Webhook, error) {
 	var webhook Webhook
 	err := json.Unmarshal(body, &webhook)
 	if err != nil {
@@ -101,7 +157,9 @@ func (c *Client) parseWebhook(body []byte) (*Webhook, error) {
 func (c *Client) logWebhook(webhook *Webhook) {
 	log.Printf("Received webhook request:")
 	fmt.Printf("Event: %s\nDocument ID: %s\nDocument Title: %s\n", webhook.Event, webhook.Payload.Model.ID, webhook.Payload.Model.Title)
-	fmt.Printf("Parent Name: %s\n", webhook.Payload.Model.ParentDocument.Title)
+	if len(webhook.Payload.Model.Categories) > 0 {
+		fmt.Printf("Categories: %s\n", strings.Join(webhook.Payload.Model.Categories, " / "))
+	}
 	fmt.Printf("Collection Name: %s\n", webhook.Payload.Model.Collection.Name)
 }
 
@@ -113,69 +171,60 @@ func formatRFC3339Time(ts string) string {
 	if err != nil {
 		return ts
 	}
-	return parsed.In(time.Local).Format("2006-01-02T15:04:05.000")
+	return parsed.In(time.Local).Format(generator.TimeLayout)
 }
 
-func (c *Client) HandleWebhook(w http.ResponseWriter, r *http.Request) {
-	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 10*1024*1024))
-	if err != nil {
-		log.Printf("Error reading webhook - %v", err)
-		http.Error(w, "Error reading webhook", http.StatusBadRequest)
-		return
+// handleEvent applies webhook to the site. Failures are returned so the event is
+// retried, events that do not concern the site are skipped without an error.
+func (c *Client) handleEvent(ctx context.Context, webhook *Webhook) error {
+	if c.isDuplicate(webhook) {
+		log.Printf("Duplicate delivery of %s for %s - Skipping", webhook.Event, webhook.Payload.Model.ID)
+		return nil
 	}
-
-	err = c.verifyWebhook(body, r.Header.Get("Outline-Signature"))
-	if err != nil {
-		log.Printf("Error verifying webhook - %v", err)
-		http.Error(w, "Error verifying webhook", http.StatusUnauthorized)
-		return
+	err := c.applyEvent(ctx, webhook)
+	if err == nil {
+		// Only once applied, a failed delivery is retried by the queue and by Outline
+		c.markDelivered(webhook)
 	}
+	return err
+}
 
-	webhook, err := c.parseWebhook(body)
-	if err != nil {
-		log.Printf("Error parsing webhook - %v", err)
-		http.Error(w, "Error parsing webhook", http.StatusBadRequest)
-		return
+func (c *Client) applyEvent(ctx context.Context, webhook *Webhook) error {
+	c.invalidateCache(webhook)
+	if strings.HasPrefix(webhook.Event, "collections.") {
+		return nil
 	}
 
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte("Acknowledged"))
-
-	if webhook.Payload.Model.ParentDocumentID != "" {
-		parentDocument, err := c.GetDocument(webhook.Payload.Model.ParentDocumentID)
-		if err != nil {
-			log.Printf("Error fetching parent document info - %v", err)
-			return
-		}
-		webhook.Payload.Model.ParentDocument = &parentDocument
-	} else {
-		webhook.Payload.Model.ParentDocument = &DocumentPayload{
-			Title: "null",
-		}
+	uses, err := c.usesCollection(ctx, webhook.Payload.Model.CollectionID)
+	var notFound *collectionNotFoundError
+	if errors.As(err, &notFound) {
+		// Not retried, it would hold up the queue until the config is fixed
+		log.Printf("Error resolving blog collections - %v - Skipping", err)
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("Error resolving blog collections - %w", err)
+	}
+	if !uses {
+		// log.Printf("Not desired collection - Skipping")
+		// Commented out to reduce log noise
+		return nil
 	}
 
-	collection, err := c.GetCollection(webhook.Payload.Model.CollectionID)
+	collection, err := c.collectionInfo(ctx, webhook.Payload.Model.CollectionID)
 	webhook.Payload.Model.Collection = &collection
 	if err != nil {
-		log.Printf("Error fetching collection info - %v", err)
-		return
-	}
-
-	if collection.Name != c.cfg.OutlineCollectionUsedForBlog {
-		// log.Printf("Not desired collection - Skipping")
-		// Commented out to reduce log noise
-		return
+		return fmt.Errorf("Error fetching collection info - %w", err)
 	}
 
 	switch webhook.Event {
 	case "documents.create":
 		c.logWebhook(webhook)
-		if webhook.Payload.Model.ParentDocumentID == "" {
+		if !c.isPostDocument(&webhook.Payload.Model) {
 			log.Printf("Document has no parent - Skipping")
-			return
+			return nil
 		}
 		c.justCreatedOrUpdated.Store(webhook.Payload.Model.ID, true)
-		go c.unpublishDocument(webhook.Payload.Model.ID)
+		go c.unpublishDocument(ctx, webhook.Payload.Model.ID)
 		time.AfterFunc(time.Second*10, func() {
 			c.justCreatedOrUpdated.Delete(webhook.Payload.Model.ID)
 		})
@@ -184,7 +233,7 @@ func (c *Client) HandleWebhook(w http.ResponseWriter, r *http.Request) {
 		_, justCreated := c.justCreatedOrUpdated.Load(webhook.Payload.Model.ID)
 		if justCreated {
 			log.Printf("Document just created - Ignoring publish event")
-			return
+			return nil
 		}
 		fallthrough
 	case "documents.unarchive":
@@ -194,129 +243,193 @@ func (c *Client) HandleWebhook(w http.ResponseWriter, r *http.Request) {
 	case "documents.move":
 		fallthrough
 	case "documents.title_change":
+		// Only posts being written need their categories, removing works without the parents
+		categories, err := c.categoriesOf(ctx, &webhook.Payload.Model, nil)
+		if err != nil {
+			return fmt.Errorf("Error fetching parent document info - %w", err)
+		}
+		webhook.Payload.Model.Categories = categories
 		c.logWebhook(webhook)
-		if webhook.Payload.Model.ParentDocumentID == "" {
+		if !c.isPostDocument(&webhook.Payload.Model) {
 			log.Printf("Document has no parent - Skipping")
-			return
+			return nil
 		}
-
-		post := &hexo.Post{
-			ID:       webhook.Payload.Model.ID,
-			Title:    webhook.Payload.Model.Title,
-			Date:     formatRFC3339Time(webhook.Payload.Model.CreatedAt),
-			Updated:  formatRFC3339Time(webhook.Payload.Model.UpdatedAt),
-			Category: webhook.Payload.Model.ParentDocument.Title,
-			Content:  webhook.Payload.Model.Text,
-		}
-		post.Content, err = processor.ConvertAttachmentUrl(c, post.Content)
-		if err != nil {
-			log.Printf("Error converting attachment URLs - %v", err)
-			return
+		if c.isOutdated(&webhook.Payload.Model) {
+			log.Printf("Event is older than the applied document - Skipping")
+			return nil
 		}
-		metadataAndText := processor.ExtractMetadataAndText(post.Content)
-		post.BannerImg = metadataAndText.BannerImg
-		post.IndexImg = metadataAndText.IndexImg
-		post.Tags = metadataAndText.Tags
-		post.Archive = metadataAndText.Archive
-		post.Content = metadataAndText.Text
-
-		err := hexo.CreateHexoPost(c.cfg.HexoSourcePostDir, post)
+
+		err = c.writePost(ctx, &webhook.Payload.Model)
 		if err != nil {
-			log.Printf("Error creating Hexo post - %v", err)
-			return
+			return fmt.Errorf("Error creating post - %w", err)
 		}
-		c.hexoTrigger.TriggerBuild()
-		return
+		c.generator.TriggerBuild()
+		return nil
 
 	case "documents.unpublish":
 		_, justCreated := c.justCreatedOrUpdated.Load(webhook.Payload.Model.ID)
 		if justCreated {
 			log.Printf("Document just created or updated - Ignoring unpublish event")
 			c.justCreatedOrUpdated.Delete(webhook.Payload.Model.ID)
-			return
+			return nil
 		}
 		fallthrough
 	case "documents.archive":
 		fallthrough
 	case "documents.delete":
 		c.logWebhook(webhook)
-		if webhook.Payload.Model.ParentDocumentID == "" {
+		if !c.isPostDocument(&webhook.Payload.Model) {
 			log.Printf("Document has no parent - Skipping")
-			return
+			return nil
+		}
+		if c.isOutdated(&webhook.Payload.Model) {
+			log.Printf("Event is older than the applied document - Skipping")
+			return nil
 		}
 
-		err := hexo.RemoveHexoPost(c.cfg.HexoSourcePostDir, webhook.Payload.Model.ID)
-		if err != nil {
-			log.Printf("Error removing Hexo post - %v", err)
-			return
+		err := c.removePost(&webhook.Payload.Model)
+		if errors.Is(err, os.ErrNotExist) {
+			log.Printf("Post does not exist - Skipping")
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("Error removing post - %w", err)
 		}
-		c.hexoTrigger.TriggerBuild()
-		return
+		c.generator.TriggerBuild()
+		return nil
 
 	case "documents.update":
-		if c.cfg.OutlineUnpublishWhenUpdated {
-			if webhook.Payload.Model.ParentDocumentID == "" {
-				return
+		if c.config().OutlineUnpublishWhenUpdated {
+			if !c.isPostDocument(&webhook.Payload.Model) {
+				return nil
 			}
 			if webhook.Payload.Model.PublishedAt == "" {
-				return
+				return nil
 			} else {
 				c.justCreatedOrUpdated.Store(webhook.Payload.Model.ID, true)
-				c.unpublishDocument(webhook.Payload.Model.ID)
+				err := c.unpublishDocument(ctx, webhook.Payload.Model.ID)
+				if err != nil {
+					c.justCreatedOrUpdated.Delete(webhook.Payload.Model.ID)
+					return fmt.Errorf("Error unpublishing document - %w", err)
+				}
 				time.AfterFunc(time.Second*10, func() {
 					c.justCreatedOrUpdated.Delete(webhook.Payload.Model.ID)
 				})
 			}
 		}
-		return
+		return nil
 
 	default:
 		log.Printf("Unhandled event type - %s", webhook.Event)
 	}
+	return nil
 }
 
-func (c *Client) newRequest(endpoint string, reqPayload any) (*http.Request, error) {
-	var buf bytes.Buffer
-	encoder := json.NewEncoder(&buf)
-	err := encoder.Encode(reqPayload)
+// buildPost processes the text of doc into a post, resolving attachments with attachments.
+func (c *Client) buildPost(ctx context.Context, doc *DocumentPayload, attachments processor.AttachmentUrlProvider) (*generator.Post, *processor.MetadataAndText, error) {
+	post := &generator.Post{
+		ID:         doc.ID,
+		Title:      doc.Title,
+		Date:       formatRFC3339Time(doc.CreatedAt),
+		Updated:    formatRFC3339Time(doc.UpdatedAt),
+		Categories: doc.Categories,
+		Content:    doc.Text,
+	}
+
+	env := &processor.Env{
+		DocumentID:  doc.ID,
+		OutlineURL:  c.outlineURL(),
+		Attachments: attachments,
+		Documents:   c,
+		Generator:   c.config().Generator,
+	}
+	metadataAndText, err := c.pipeline.Load().Process(ctx, env, post.Content)
 	if err != nil {
-		return nil, err
+		return nil, nil, fmt.Errorf("Error processing document text - %w", err)
+	}
+	post.BannerImg = metadataAndText.BannerImg
+	post.IndexImg = metadataAndText.IndexImg
+	post.Tags = metadataAndText.Tags
+	post.Archive = metadataAndText.Archive
+	post.Math = metadataAndText.Math
+	post.Mermaid = metadataAndText.Mermaid
+	post.FrontMatter = metadataAndText.FrontMatter
+	post.Content = metadataAndText.Text
+	return post, metadataAndText, nil
+}
+
+func (c *Client) writePost(ctx context.Context, doc *DocumentPayload) error {
+	var attachments processor.AttachmentUrlProvider = c
+	if c.config().AttachmentMode == config.AttachmentModeDownload {
+		attachments = &attachmentDownloader{client: c, documentID: doc.ID}
 	}
+	post, _, err := c.buildPost(ctx, doc, attachments)
+	if err != nil {
+		return err
+	}
+	previousName := c.currentPostName(doc.ID)
+	post.Name = c.reservePostName(doc)
+	defer c.releasePostName(doc.ID)
 
-	req, err := http.NewRequest("POST", c.cfg.OutlineAPIURL+endpoint, &buf)
+	err = c.generator.CreatePost(post)
 	if err != nil {
-		return nil, err
+		return err
+	}
+	if previousName != post.Name {
+		// Renamed, drop the post under the old name so it does not show up twice
+		err = c.generator.RemovePost(previousName)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Printf("Error removing renamed post - %v", err)
+		}
+	}
+
+	err = c.store.Update(doc.ID, func(applied *state.Document) {
+		if doc.URLID != "" {
+			applied.URLID = doc.URLID
+		}
+		applied.UpdatedAt = doc.UpdatedAt
+		applied.FileName = post.Name
+		c.recordPermalink(applied, post)
+	})
+	if err != nil {
+		log.Printf("Error saving document state - %v", err)
 	}
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+c.cfg.OutlineAPIKey)
-	return req, nil
+	return nil
 }
 
-func getInfoByID[T any](c *Client, endpoint string, id string) (T, error) {
-	reqPayload := RequestPayload{ID: id}
-	var zero T
+// removePost removes the post of doc and its downloaded attachments from the site
+// sources. A missing post is reported with os.ErrNotExist after the rest is cleaned up.
+func (c *Client) removePost(doc *DocumentPayload) error {
+	postErr := c.generator.RemovePost(c.currentPostName(doc.ID))
+	if postErr != nil && !errors.Is(postErr, os.ErrNotExist) {
+		return postErr
+	}
+	err := c.removeAttachments(doc.ID)
+	if err != nil {
+		return err
+	}
 
-	req, err := c.newRequest(endpoint, reqPayload)
+	err = c.store.Update(doc.ID, func(applied *state.Document) {
+		if doc.UpdatedAt != "" {
+			applied.UpdatedAt = doc.UpdatedAt
+		}
+		applied.FileName = ""
+		applied.Attachments = nil
+	})
 	if err != nil {
-		return zero, err
+		log.Printf("Error saving document state - %v", err)
 	}
+	return postErr
+}
+
+func callAPI[T any](ctx context.Context, c *Client, endpoint string, reqPayload any) (T, error) {
+	var zero T
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.doRequest(ctx, c.httpClient, endpoint, reqPayload, http.StatusOK)
 	if err != nil {
 		return zero, err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		var apiErr APIError
-		decoder := json.NewDecoder(resp.Body)
-		err = decoder.Decode(&apiErr)
-		if err != nil {
-			return zero, err
-		}
-		return zero, fmt.Errorf("Unexpected API http status - %d, %s", resp.StatusCode, apiErr.Error())
-	}
-
 	var response struct {
 		Data T `json:"data"`
 	}
@@ -328,41 +441,46 @@ func getInfoByID[T any](c *Client, endpoint string, id string) (T, error) {
 	return response.Data, nil
 }
 
-func (c *Client) GetDocument(id string) (DocumentPayload, error) {
-	return getInfoByID[DocumentPayload](c, "/documents.info", id)
+func getInfoByID[T any](ctx context.Context, c *Client, endpoint string, id string) (T, error) {
+	return callAPI[T](ctx, c, endpoint, RequestPayload{ID: id})
 }
 
-func (c *Client) GetCollection(id string) (CollectionPayload, error) {
-	return getInfoByID[CollectionPayload](c, "/collections.info", id)
+func (c *Client) GetDocument(ctx context.Context, id string) (DocumentPayload, error) {
+	return getInfoByID[DocumentPayload](ctx, c, "/documents.info", id)
 }
 
-func (c *Client) GetAttachmentUrl(id string) (string, error) {
-	reqPayload := RequestPayload{ID: id}
-	req, err := c.newRequest("/attachments.redirect", reqPayload)
-	if err != nil {
-		return "", err
-	}
+func (c *Client) GetCollection(ctx context.Context, id string) (CollectionPayload, error) {
+	return getInfoByID[CollectionPayload](ctx, c, "/collections.info", id)
+}
 
-	resp, err := c.httpClientNoRedirect.Do(req)
+func (c *Client) ListCollections(ctx context.Context, offset, limit int) ([]CollectionPayload, error) {
+	return callAPI[[]CollectionPayload](ctx, c, "/collections.list", ListPayload{Offset: offset, Limit: limit})
+}
+
+func (c *Client) ListDocuments(ctx context.Context, collectionID string, offset, limit int) ([]DocumentPayload, error) {
+	return callAPI[[]DocumentPayload](ctx, c, "/documents.list", ListPayload{CollectionID: collectionID, Offset: offset, Limit: limit})
+}
+
+// getAttachmentLocation returns where Outline redirects to for an attachment, including any presigned query.
+func (c *Client) getAttachmentLocation(ctx context.Context, id string) (string, error) {
+	resp, err := c.doRequest(ctx, c.httpClientNoRedirect, "/attachments.redirect", RequestPayload{ID: id}, http.StatusFound)
 	if err != nil {
 		return "", err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusFound {
-		var apiErr APIError
-		decoder := json.NewDecoder(resp.Body)
-		err = decoder.Decode(&apiErr)
-		if err != nil {
-			return "", err
-		}
-		return "", fmt.Errorf("Unexpected API http status - %d, %s", resp.StatusCode, apiErr.Error())
-	}
-
 	location := resp.Header.Get("Location")
 	if location == "" {
 		return "", fmt.Errorf("Missing Location header in API response")
 	}
+	return location, nil
+}
+
+func (c *Client) GetAttachmentUrl(ctx context.Context, id string) (string, error) {
+	location, err := c.getAttachmentLocation(ctx, id)
+	if err != nil {
+		return "", err
+	}
 
 	// Cut off S3 presigned part, the bucket should be public-readable
 	if index := strings.Index(location, "?"); index != -1 {
@@ -371,27 +489,11 @@ func (c *Client) GetAttachmentUrl(id string) (string, error) {
 	return location, nil
 }
 
-func (c *Client) unpublishDocument(id string) error {
-	reqPayload := RequestPayload{ID: id}
-	req, err := c.newRequest("/documents.unpublish", reqPayload)
-	if err != nil {
-		return err
-	}
-
-	resp, err := c.httpClient.Do(req)
+func (c *Client) unpublishDocument(ctx context.Context, id string) error {
+	resp, err := c.doRequest(ctx, c.httpClient, "/documents.unpublish", RequestPayload{ID: id}, http.StatusOK)
 	if err != nil {
 		return err
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		var apiErr APIError
-		decoder := json.NewDecoder(resp.Body)
-		err = decoder.Decode(&apiErr)
-		if err != nil {
-			return err
-		}
-		return fmt.Errorf("Unexpected API http status - %d, %s", resp.StatusCode, apiErr.Error())
-	}
+	resp.Body.Close()
 	return nil
 }
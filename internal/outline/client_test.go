@@ -0,0 +1,296 @@
+package outline
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"outline-hexo-connector/internal/config"
+	"outline-hexo-connector/internal/generator"
+	"outline-hexo-connector/internal/processor"
+	"outline-hexo-connector/internal/state"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeGenerator keeps posts in memory.
+type fakeGenerator struct {
+	mu       sync.Mutex
+	posts    map[string]*generator.Post
+	triggers int
+}
+
+func newFakeGenerator() *fakeGenerator {
+	return &fakeGenerator{posts: make(map[string]*generator.Post)}
+}
+
+func (g *fakeGenerator) CreatePost(post *generator.Post) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.posts[post.Name] = post
+	return nil
+}
+
+func (g *fakeGenerator) RenderPost(post *generator.Post) (string, error) {
+	return post.Content, nil
+}
+
+func (g *fakeGenerator) RemovePost(name string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if _, ok := g.posts[name]; !ok {
+		return os.ErrNotExist
+	}
+	delete(g.posts, name)
+	return nil
+}
+
+func (g *fakeGenerator) PostExists(name string) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	_, ok := g.posts[name]
+	return ok
+}
+
+func (g *fakeGenerator) ListPosts() (map[string]time.Time, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	posts := make(map[string]time.Time, len(g.posts))
+	for name := range g.posts {
+		posts[name] = time.Now()
+	}
+	return posts, nil
+}
+
+func (g *fakeGenerator) TriggerBuild() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.triggers++
+}
+
+func (g *fakeGenerator) Build() error                   { return nil }
+func (g *fakeGenerator) SetBeforeBuild(fn func() error) {}
+func (g *fakeGenerator) Watch(ctx context.Context)      {}
+func (g *fakeGenerator) Reconfigure(cfg *config.Config) (func(), error) {
+	return func() {}, nil
+}
+
+// fakeOutline answers the API calls the client makes from documents and collections,
+// and fails every call while down is set.
+type fakeOutline struct {
+	mu          sync.Mutex
+	down        bool
+	collections []CollectionPayload
+	documents   map[string]DocumentPayload
+}
+
+func (o *fakeOutline) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.down {
+		http.Error(w, `{"error":"unavailable"}`, http.StatusBadRequest)
+		return
+	}
+
+	var request struct {
+		ID string `json:"id"`
+	}
+	json.NewDecoder(r.Body).Decode(&request)
+
+	var data any
+	switch r.URL.Path {
+	case "/api/collections.list":
+		data = o.collections
+	case "/api/collections.info":
+		for _, collection := range o.collections {
+			if collection.ID == request.ID {
+				data = collection
+			}
+		}
+	case "/api/documents.info":
+		for _, doc := range o.documents {
+			if doc.ID == request.ID || doc.URLID == request.ID {
+				data = doc
+			}
+		}
+	case "/api/documents.list":
+		var docs []DocumentPayload
+		for _, doc := range o.documents {
+			docs = append(docs, doc)
+		}
+		data = docs
+	}
+	if data == nil {
+		http.Error(w, `{"error":"not_found"}`, http.StatusNotFound)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]any{"data": data})
+}
+
+func newTestClient(t *testing.T, outline http.Handler, configure func(cfg *config.Config)) (*Client, *fakeGenerator) {
+	t.Helper()
+	server := httptest.NewServer(outline)
+	t.Cleanup(server.Close)
+
+	cfg := &config.Config{OutlineAPIURL: server.URL + "/api", OutlineAPIKey: "key"}
+	cfg.OutlineCollectionUsedForBlog = "Blog"
+	cfg.HexoSourcePostDir = t.TempDir()
+	cfg.DataDir = t.TempDir()
+	if configure != nil {
+		configure(cfg)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Logf("Config problems, fine for the test - %v", err)
+	}
+
+	store, err := state.Open(cfg.DataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pipeline, err := processor.NewPipeline(cfg.ProcessorStages)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gen := newFakeGenerator()
+	return NewClient(cfg, gen, pipeline, store), gen
+}
+
+func TestFailedDeliveryIsRetried(t *testing.T) {
+	outline := &fakeOutline{
+		collections: []CollectionPayload{{ID: "c1", Name: "Blog"}},
+		documents: map[string]DocumentPayload{
+			"parent": {ID: "parent", Title: "Go", CollectionID: "c1"},
+		},
+	}
+	client, gen := newTestClient(t, outline, nil)
+
+	webhook := &Webhook{WebhookSubscriptionID: "sub", Event: "documents.publish"}
+	webhook.Payload.Model = DocumentPayload{
+		ID:               "doc",
+		Title:            "Hello",
+		Text:             "Hello world",
+		CollectionID:     "c1",
+		ParentDocumentID: "parent",
+		PublishedAt:      "2024-01-01T00:00:00.000Z",
+		UpdatedAt:        "2024-01-01T00:00:00.000Z",
+	}
+
+	outline.down = true
+	if err := client.handleEvent(context.Background(), webhook); err == nil {
+		t.Fatal("handleEvent succeeded with Outline down")
+	}
+	if len(gen.posts) != 0 {
+		t.Fatalf("posts = %v, want none", gen.posts)
+	}
+
+	outline.down = false
+	retry := *webhook
+	if err := client.handleEvent(context.Background(), &retry); err != nil {
+		t.Fatalf("retry failed - %v", err)
+	}
+	if _, ok := gen.posts["doc"]; !ok {
+		t.Fatalf("posts = %v, want doc written by the retry", gen.posts)
+	}
+
+	again := *webhook
+	if err := client.handleEvent(context.Background(), &again); err != nil {
+		t.Fatal(err)
+	}
+	if gen.triggers != 1 {
+		t.Errorf("builds triggered = %d, want 1 with the duplicate skipped", gen.triggers)
+	}
+}
+
+func TestRemovePostRemovesAttachments(t *testing.T) {
+	client, gen := newTestClient(t, &fakeOutline{}, func(cfg *config.Config) {
+		cfg.AttachmentMode = config.AttachmentModeDownload
+		cfg.HexoAttachmentDir = t.TempDir()
+	})
+	dir := filepath.Join(client.config().HexoAttachmentDir, "doc")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "image.png"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	client.store.Update("doc", func(doc *state.Document) {
+		doc.FileName = "doc"
+		doc.Attachments = map[string]string{"a1": "image.png"}
+	})
+	gen.posts["doc"] = &generator.Post{Name: "doc"}
+
+	if err := client.removePost(&DocumentPayload{ID: "doc"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(dir); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("attachment directory still there - %v", err)
+	}
+	if doc, _ := client.store.Get("doc"); doc.Attachments != nil || doc.FileName != "" {
+		t.Errorf("state = %+v, want no file name and attachments", doc)
+	}
+}
+
+func TestRemoveEventsDoNotFetchParents(t *testing.T) {
+	// The parent is gone from Outline already, as when a whole tree is deleted
+	outline := &fakeOutline{collections: []CollectionPayload{{ID: "c1", Name: "Blog"}}}
+	client, gen := newTestClient(t, outline, nil)
+
+	for _, event := range []string{"documents.delete", "documents.archive", "documents.unpublish"} {
+		client.store.Update("doc", func(doc *state.Document) {
+			doc.FileName = "doc"
+		})
+		gen.posts["doc"] = &generator.Post{Name: "doc"}
+
+		webhook := &Webhook{WebhookSubscriptionID: "sub", Event: event}
+		webhook.Payload.Model = DocumentPayload{
+			ID:               "doc",
+			Title:            "Hello",
+			CollectionID:     "c1",
+			ParentDocumentID: "parent",
+			UpdatedAt:        "2024-01-01T00:00:00.000Z",
+		}
+		if err := client.applyEvent(context.Background(), webhook); err != nil {
+			t.Errorf("%s failed - %v", event, err)
+		}
+		if _, ok := gen.posts["doc"]; ok {
+			t.Errorf("%s left the post in place", event)
+		}
+	}
+}
+
+func TestUnknownCollectionSkipsEvents(t *testing.T) {
+	outline := &fakeOutline{
+		collections: []CollectionPayload{{ID: "c1", Name: "Blog"}},
+		documents: map[string]DocumentPayload{
+			"parent": {ID: "parent", Title: "Go", CollectionID: "c1"},
+		},
+	}
+	client, gen := newTestClient(t, outline, func(cfg *config.Config) {
+		cfg.OutlineCollectionUsedForBlog = "Renamed"
+	})
+
+	webhook := &Webhook{WebhookSubscriptionID: "sub", Event: "documents.publish"}
+	webhook.Payload.Model = DocumentPayload{
+		ID:               "doc",
+		Title:            "Hello",
+		CollectionID:     "c1",
+		ParentDocumentID: "parent",
+		PublishedAt:      "2024-01-01T00:00:00.000Z",
+	}
+	if err := client.applyEvent(context.Background(), webhook); err != nil {
+		t.Errorf("applyEvent = %v, want the event skipped", err)
+	}
+	if len(gen.posts) != 0 {
+		t.Errorf("posts = %v, want none", gen.posts)
+	}
+
+	// Outline being unreachable is still retried
+	outline.down = true
+	if err := client.applyEvent(context.Background(), webhook); err == nil {
+		t.Error("applyEvent succeeded with Outline down")
+	}
+}
@@ -0,0 +1,117 @@
+package outline
+
+import (
+	"context"
+	"fmt"
+)
+
+// collectionNotFoundError is returned when no collection has a name the site is set to
+// publish. Retrying does not help until the collection is created or the config fixed.
+type collectionNotFoundError struct {
+	name string
+}
+
+func (e *collectionNotFoundError) Error() string {
+	return fmt.Sprintf("Collection %q not found", e.name)
+}
+
+// blogCollectionIDs returns the IDs of the collections the site publishes. Collections
+// set by name are looked up once, so renaming them later does not matter.
+func (c *Client) blogCollectionIDs(ctx context.Context) ([]string, error) {
+	c.collectionIDsMu.Lock()
+	defer c.collectionIDsMu.Unlock()
+	if c.collectionIDs != nil {
+		return c.collectionIDs, nil
+	}
+
+	names := c.config().CollectionNames()
+	if len(names) == 0 && len(c.config().OutlineCollectionIDs) == 0 {
+		return nil, fmt.Errorf("No collection configured for the blog")
+	}
+
+	found := make(map[string]string)
+	if len(names) > 0 {
+		wanted := make(map[string]bool, len(names))
+		for _, name := range names {
+			wanted[name] = true
+		}
+		for offset := 0; ; offset += listPageSize {
+			collections, err := c.ListCollections(ctx, offset, listPageSize)
+			if err != nil {
+				return nil, err
+			}
+			for _, collection := range collections {
+				if wanted[collection.Name] {
+					found[collection.Name] = collection.ID
+					c.collections.set(collection.ID, collection)
+				}
+			}
+			if len(collections) < listPageSize {
+				break
+			}
+		}
+	}
+
+	ids := []string{}
+	seen := make(map[string]bool)
+	add := func(id string) {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	for _, name := range names {
+		id, ok := found[name]
+		if !ok {
+			return nil, &collectionNotFoundError{name: name}
+		}
+		add(id)
+	}
+	for _, id := range c.config().OutlineCollectionIDs {
+		add(id)
+	}
+
+	c.collectionIDs = ids
+	return ids, nil
+}
+
+// ResolveCollections looks up the collections set by name ahead of the first webhook.
+func (c *Client) ResolveCollections(ctx context.Context) error {
+	_, err := c.blogCollectionIDs(ctx)
+	return err
+}
+
+// usesCollection reports whether the site publishes the collection with the ID id.
+func (c *Client) usesCollection(ctx context.Context, id string) (bool, error) {
+	if id == "" {
+		return false, nil
+	}
+	ids, err := c.blogCollectionIDs(ctx)
+	if err != nil {
+		return false, err
+	}
+	for _, blogID := range ids {
+		if blogID == id {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// findBlogCollections returns the collections the site publishes.
+func (c *Client) findBlogCollections(ctx context.Context) ([]CollectionPayload, error) {
+	ids, err := c.blogCollectionIDs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	collections := make([]CollectionPayload, 0, len(ids))
+	for _, id := range ids {
+		collection, err := c.collectionInfo(ctx, id)
+		if err != nil {
+			return nil, fmt.Errorf("Error fetching collection info of %s - %w", id, err)
+		}
+		collections = append(collections, collection)
+	}
+	return collections, nil
+}
@@ -0,0 +1,46 @@
+package outline
+
+import (
+	"time"
+)
+
+const deliveryDedupeWindow = time.Hour
+
+func deliveryKey(webhook *Webhook) string {
+	model := webhook.Payload.Model
+	return webhook.WebhookSubscriptionID + "|" + webhook.Event + "|" + model.ID + "|" + model.UpdatedAt
+}
+
+// isDuplicate reports whether the same delivery has already been applied recently.
+func (c *Client) isDuplicate(webhook *Webhook) bool {
+	_, seen := c.recentDeliveries.Load(deliveryKey(webhook))
+	return seen
+}
+
+// markDelivered records that webhook has been applied.
+func (c *Client) markDelivered(webhook *Webhook) {
+	key := deliveryKey(webhook)
+	_, seen := c.recentDeliveries.LoadOrStore(key, true)
+	if !seen {
+		time.AfterFunc(deliveryDedupeWindow, func() {
+			c.recentDeliveries.Delete(key)
+		})
+	}
+}
+
+// isOutdated reports whether doc is older than what has already been applied to the site.
+func (c *Client) isOutdated(doc *DocumentPayload) bool {
+	applied, ok := c.store.Get(doc.ID)
+	if !ok || applied.UpdatedAt == "" {
+		return false
+	}
+	appliedTime, err := time.Parse(time.RFC3339Nano, applied.UpdatedAt)
+	if err != nil {
+		return false
+	}
+	docTime, err := time.Parse(time.RFC3339Nano, doc.UpdatedAt)
+	if err != nil {
+		return false
+	}
+	return docTime.Before(appliedTime)
+}
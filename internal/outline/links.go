@@ -0,0 +1,62 @@
+package outline
+
+import (
+	"context"
+	"net/url"
+	"outline-hexo-connector/internal/generator"
+	"outline-hexo-connector/internal/processor"
+	"outline-hexo-connector/internal/redirect"
+)
+
+// outlineURL returns the base URL of the Outline instance, from the API URL.
+func (c *Client) outlineURL() string {
+	apiURL, err := url.Parse(c.config().OutlineAPIURL)
+	if err != nil || apiURL.Host == "" {
+		return ""
+	}
+	return apiURL.Scheme + "://" + apiURL.Host
+}
+
+// ResolveDocumentLink looks up the post of the document with the URL ID urlID. Documents
+// not written yet, such as during the first sync, are fetched from Outline and the name
+// their post would be written under is returned, without keeping it for them.
+func (c *Client) ResolveDocumentLink(ctx context.Context, urlID string) (processor.LinkedPost, bool, error) {
+	if _, applied, ok := c.store.ByURLID(urlID); ok && applied.FileName != "" {
+		return processor.LinkedPost{Name: applied.FileName, Permalink: applied.Permalink}, true, nil
+	}
+
+	// documents.info takes the URL ID as well
+	doc, err := c.GetDocument(ctx, urlID)
+	if err != nil {
+		return processor.LinkedPost{}, false, err
+	}
+	if doc.PublishedAt == "" || !c.isPostDocument(&doc) {
+		return processor.LinkedPost{}, false, nil
+	}
+	uses, err := c.usesCollection(ctx, doc.CollectionID)
+	if err != nil || !uses {
+		return processor.LinkedPost{}, false, err
+	}
+	collection, err := c.collectionInfo(ctx, doc.CollectionID)
+	if err != nil {
+		return processor.LinkedPost{}, false, err
+	}
+	doc.Collection = &collection
+	doc.Categories, err = c.categoriesOf(ctx, &doc, nil)
+	if err != nil {
+		return processor.LinkedPost{}, false, err
+	}
+
+	linked := processor.LinkedPost{Name: c.postName(&doc)}
+	if c.config().RedirectPermalink != "" {
+		post := &generator.Post{
+			ID:         doc.ID,
+			Name:       linked.Name,
+			Title:      doc.Title,
+			Date:       formatRFC3339Time(doc.CreatedAt),
+			Categories: doc.Categories,
+		}
+		linked.Permalink = redirect.URLPath(c.config().RedirectRoot, redirect.Permalink(c.config().RedirectPermalink, post))
+	}
+	return linked, true, nil
+}
@@ -0,0 +1,72 @@
+package outline
+
+import (
+	"context"
+	"outline-hexo-connector/internal/config"
+	"outline-hexo-connector/internal/state"
+	"testing"
+)
+
+func TestResolveDocumentLinkNotSyncedYet(t *testing.T) {
+	linked := DocumentPayload{
+		ID:               "linked",
+		URLID:            "AbCdEf1234",
+		Title:            "Same",
+		Text:             "Linked",
+		CollectionID:     "c1",
+		ParentDocumentID: "parent",
+		PublishedAt:      "2024-01-01T00:00:00.000Z",
+		CreatedAt:        "2024-01-02T03:04:05.000Z",
+	}
+	outline := &fakeOutline{
+		collections: []CollectionPayload{{ID: "c1", Name: "Blog"}},
+		documents: map[string]DocumentPayload{
+			"parent": {ID: "parent", Title: "Go", CollectionID: "c1"},
+			"linked": linked,
+		},
+	}
+	client, _ := newTestClient(t, outline, func(cfg *config.Config) {
+		cfg.PostFileName = config.PostFileNameSlug
+		cfg.RedirectPermalink = ":category/:title/"
+	})
+	ctx := context.Background()
+
+	post, found, err := client.ResolveDocumentLink(ctx, linked.URLID)
+	if err != nil || !found {
+		t.Fatalf("ResolveDocumentLink = %+v, %v, %v", post, found, err)
+	}
+	if post.Name != "same" || post.Permalink != "/Go/same/" {
+		t.Errorf("ResolveDocumentLink = %+v, want same at /Go/same/", post)
+	}
+	if len(client.reservedPostNames) != 0 {
+		t.Errorf("names kept after resolving a link - %v", client.reservedPostNames)
+	}
+	if _, _, ok := client.store.ByURLID(linked.URLID); ok {
+		t.Error("resolving a link recorded state for the linked document")
+	}
+
+	// A link resolved during a dry run does not hold the name
+	if err := client.writePost(ctx, &DocumentPayload{ID: "other", Title: "Same", Text: "Other"}); err != nil {
+		t.Fatal(err)
+	}
+	if name := client.currentPostName("other"); name != "same" {
+		t.Errorf("other written as %q, want same", name)
+	}
+
+	if err := client.writePost(ctx, &linked); err != nil {
+		t.Fatal(err)
+	}
+	// Known now, found through the store without asking Outline
+	outline.down = true
+	post, found, err = client.ResolveDocumentLink(ctx, linked.URLID)
+	if err != nil || !found || post.Name != client.currentPostName("linked") {
+		t.Errorf("ResolveDocumentLink = %+v, %v, %v, want %s from the store", post, found, err, client.currentPostName("linked"))
+	}
+
+	client.store.Update("linked", func(doc *state.Document) {
+		doc.URLID = "XyXyXy0000"
+	})
+	if _, _, ok := client.store.ByURLID(linked.URLID); ok {
+		t.Error("old URL ID still indexed")
+	}
+}
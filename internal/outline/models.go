@@ -14,6 +14,7 @@ type Webhook struct {
 
 type DocumentPayload struct {
 	ID               string `json:"id"`
+	URLID            string `json:"urlId"`
 	Title            string `json:"title"`
 	Text             string `json:"text"`
 	CreatedAt        string `json:"createdAt"`
@@ -23,6 +24,7 @@ type DocumentPayload struct {
 	ParentDocumentID string `json:"parentDocumentId"`
 	ParentDocument   *DocumentPayload
 	Collection       *CollectionPayload
+	Categories       []string `json:"-"`
 }
 
 type CollectionPayload struct {
@@ -44,6 +46,12 @@ type RequestPayload struct {
 	ID string `json:"id"`
 }
 
+type ListPayload struct {
+	CollectionID string `json:"collectionId,omitempty"`
+	Offset       int    `json:"offset"`
+	Limit        int    `json:"limit"`
+}
+
 type APIError struct {
 	Err     string `json:"error"`
 	Message string `json:"message"`
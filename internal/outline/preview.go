@@ -0,0 +1,70 @@
+package outline
+
+import (
+	"context"
+	"fmt"
+	"outline-hexo-connector/internal/config"
+	"outline-hexo-connector/internal/processor"
+)
+
+// Preview is the post of a document rendered without writing anything.
+type Preview struct {
+	Name      string
+	Content   string
+	Processed *processor.MetadataAndText
+}
+
+// previewAttachments resolves attachments without downloading them. Attachments that
+// were downloaded before keep their site URL, others link to the storage bucket.
+type previewAttachments struct {
+	client     *Client
+	downloader *attachmentDownloader
+}
+
+func (p *previewAttachments) GetAttachmentUrl(ctx context.Context, id string) (string, error) {
+	if p.downloader != nil {
+		if siteUrl, ok := p.downloader.stored(id); ok {
+			return siteUrl, nil
+		}
+	}
+	return p.client.GetAttachmentUrl(ctx, id)
+}
+
+// LoadDocument fetches document id with its collection and categories filled in.
+func (c *Client) LoadDocument(ctx context.Context, id string) (*DocumentPayload, error) {
+	doc, err := c.GetDocument(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("Error fetching document info - %w", err)
+	}
+	collection, err := c.collectionInfo(ctx, doc.CollectionID)
+	if err != nil {
+		return nil, fmt.Errorf("Error fetching collection info - %w", err)
+	}
+	doc.Collection = &collection
+	doc.Categories, err = c.categoriesOf(ctx, &doc, nil)
+	if err != nil {
+		return nil, fmt.Errorf("Error fetching parent document info - %w", err)
+	}
+	return &doc, nil
+}
+
+// Preview renders the post of doc the way writePost would, without touching the site
+// sources, the document state or the build.
+func (c *Client) Preview(ctx context.Context, doc *DocumentPayload) (*Preview, error) {
+	attachments := &previewAttachments{client: c}
+	if c.config().AttachmentMode == config.AttachmentModeDownload {
+		attachments.downloader = &attachmentDownloader{client: c, documentID: doc.ID}
+	}
+
+	post, processed, err := c.buildPost(ctx, doc, attachments)
+	if err != nil {
+		return nil, err
+	}
+	post.Name = c.postName(doc)
+
+	content, err := c.generator.RenderPost(post)
+	if err != nil {
+		return nil, fmt.Errorf("Error rendering post - %w", err)
+	}
+	return &Preview{Name: post.Name, Content: content, Processed: processed}, nil
+}
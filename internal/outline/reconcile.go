@@ -0,0 +1,150 @@
+package outline
+
+import (
+	"context"
+	"log"
+	"outline-hexo-connector/internal/state"
+	"time"
+)
+
+type ReconcileResult struct {
+	Created int
+	Updated int
+	Removed int
+}
+
+func (r ReconcileResult) Changed() bool {
+	return r.Created+r.Updated+r.Removed > 0
+}
+
+func isStale(docUpdatedAt string, postUpdated time.Time) bool {
+	docTime, err := time.Parse(time.RFC3339Nano, docUpdatedAt)
+	if err != nil {
+		return false
+	}
+	// Post times are second or millisecond precision depending on the generator
+	return docTime.Truncate(time.Second).After(postUpdated)
+}
+
+// Reconcile brings the site sources in line with the published documents of the blog collections.
+// Posts of documents that are no longer published are removed, missing or outdated ones are rewritten.
+func (c *Client) Reconcile(ctx context.Context) (ReconcileResult, error) {
+	var result ReconcileResult
+
+	documents, err := c.listBlogDocuments(ctx)
+	if err != nil {
+		return result, err
+	}
+	posts, err := c.generator.ListPosts()
+	if err != nil {
+		return result, err
+	}
+
+	// Posts written by the connector, by name. Posts from before slug file names are
+	// named after the document ID.
+	owners := make(map[string]string)
+	for id, doc := range c.store.All() {
+		if doc.FileName != "" {
+			owners[doc.FileName] = id
+		}
+	}
+	for name := range posts {
+		if _, ok := owners[name]; !ok && documentIDRe.MatchString(name) {
+			owners[name] = name
+		}
+	}
+
+	for _, doc := range documents {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+
+		name := c.currentPostName(doc.ID)
+		postUpdated, exists := posts[name]
+		delete(posts, name)
+		if exists && !isStale(doc.UpdatedAt, postUpdated) && name == c.postName(doc) {
+			continue
+		}
+
+		previousName := name
+		err := c.writePost(ctx, doc)
+		if err != nil {
+			log.Printf("Error creating post for %s - %v", doc.ID, err)
+			continue
+		}
+		delete(posts, c.currentPostName(doc.ID))
+		delete(posts, previousName)
+		if exists {
+			result.Updated++
+		} else {
+			result.Created++
+		}
+	}
+
+	// Whatever is left has no published document behind it anymore
+	for name := range posts {
+		id, owned := owners[name]
+		if !owned {
+			// Not written by the connector
+			continue
+		}
+		err := c.generator.RemovePost(name)
+		if err != nil {
+			log.Printf("Error removing post - %v", err)
+			continue
+		}
+		if c.currentPostName(id) == name {
+			// The document itself is gone, not just an old name of its post
+			err = c.removeAttachments(id)
+			if err != nil {
+				log.Printf("Error removing attachments - %v", err)
+			}
+			err = c.store.Update(id, func(applied *state.Document) {
+				applied.FileName = ""
+				applied.Attachments = nil
+			})
+			if err != nil {
+				log.Printf("Error saving document state - %v", err)
+			}
+		}
+		result.Removed++
+	}
+
+	return result, nil
+}
+
+func (c *Client) reconcileAndBuild(ctx context.Context) {
+	log.Printf("Reconciling posts with Outline")
+	result, err := c.Reconcile(ctx)
+	if err != nil {
+		log.Printf("Error reconciling posts - %v - %d created, %d updated, %d removed before that", err, result.Created, result.Updated, result.Removed)
+	} else {
+		log.Printf("Reconcile finished - %d created, %d updated, %d removed", result.Created, result.Updated, result.Removed)
+	}
+	// Posts written before a failure still need a build
+	if result.Changed() {
+		c.generator.TriggerBuild()
+	}
+}
+
+// WatchReconcile runs Reconcile every Outline_Reconcile_Interval seconds until ctx is done.
+func (c *Client) WatchReconcile(ctx context.Context) {
+	if c.config().OutlineReconcileInterval <= 0 {
+		return
+	}
+	interval := time.Duration(c.config().OutlineReconcileInterval) * time.Second
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				log.Printf("Stop reconciling posts")
+				return
+			case <-ticker.C:
+				c.reconcileAndBuild(ctx)
+			}
+		}
+	}()
+}
@@ -0,0 +1,73 @@
+package outline
+
+import (
+	"outline-hexo-connector/internal/config"
+	"outline-hexo-connector/internal/generator"
+	"outline-hexo-connector/internal/redirect"
+	"outline-hexo-connector/internal/state"
+	"path/filepath"
+	"sort"
+)
+
+// recordPermalink stores the permalink of post, remembering the previous one when it changed.
+func (c *Client) recordPermalink(applied *state.Document, post *generator.Post) {
+	if c.config().RedirectPermalink == "" {
+		return
+	}
+	permalink := redirect.URLPath(c.config().RedirectRoot, redirect.Permalink(c.config().RedirectPermalink, post))
+
+	// A new slice, the old one is shared with the copies handed out by the store
+	previous := make([]string, 0, len(applied.PreviousPermalinks)+1)
+	for _, p := range applied.PreviousPermalinks {
+		if p != permalink {
+			previous = append(previous, p)
+		}
+	}
+	if applied.Permalink != "" && applied.Permalink != permalink {
+		previous = append(previous, applied.Permalink)
+	}
+	applied.PreviousPermalinks = previous
+	applied.Permalink = permalink
+}
+
+// WriteRedirects writes redirects from every previous permalink of the published posts
+// as a _redirects file, an nginx map include and meta refresh stub pages.
+func (c *Client) WriteRedirects() error {
+	if c.config().RedirectPermalink == "" {
+		return nil
+	}
+
+	docs := c.store.All()
+	current := make(map[string]bool)
+	for _, doc := range docs {
+		if doc.FileName != "" && doc.Permalink != "" {
+			current[doc.Permalink] = true
+		}
+	}
+
+	var redirects []redirect.Redirect
+	for _, doc := range docs {
+		if doc.FileName == "" || doc.Permalink == "" {
+			continue
+		}
+		for _, from := range doc.PreviousPermalinks {
+			// The old URL belongs to another post now
+			if !current[from] {
+				redirects = append(redirects, redirect.Redirect{From: from, To: doc.Permalink})
+			}
+		}
+	}
+	sort.Slice(redirects, func(i, j int) bool {
+		return redirects[i].From < redirects[j].From
+	})
+
+	err := redirect.WriteNetlify(filepath.Join(c.config().RedirectOutputDir, "_redirects"), redirects)
+	if err != nil {
+		return err
+	}
+	err = redirect.WriteNginxMap(filepath.Join(c.config().DataDir, "redirects.nginx.conf"), redirects)
+	if err != nil {
+		return err
+	}
+	return redirect.WriteStubs(c.config().RedirectOutputDir, filepath.Join(c.config().DataDir, "redirect_stubs.json"), redirects, c.config().Generator == config.GeneratorHexo)
+}
@@ -0,0 +1,59 @@
+package outline
+
+import (
+	"fmt"
+	"outline-hexo-connector/internal/config"
+	"outline-hexo-connector/internal/generator"
+	"outline-hexo-connector/internal/state"
+	"sync"
+	"testing"
+)
+
+func TestRecordPermalinkWhileWritingRedirects(t *testing.T) {
+	client, _ := newTestClient(t, &fakeOutline{}, func(cfg *config.Config) {
+		cfg.RedirectPermalink = ":title/"
+		cfg.RedirectOutputDir = t.TempDir()
+	})
+
+	// Renames between a few names so old permalinks are both kept and dropped again
+	rename := func(i int) {
+		post := &generator.Post{ID: "doc", Name: fmt.Sprintf("name-%d", i%3)}
+		err := client.store.Update("doc", func(doc *state.Document) {
+			doc.FileName = post.Name
+			client.recordPermalink(doc, post)
+		})
+		if err != nil {
+			t.Error(err)
+		}
+	}
+	for i := 0; i < 3; i++ {
+		rename(i)
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 200; i++ {
+			rename(i)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 200; i++ {
+			if err := client.WriteRedirects(); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+	wg.Wait()
+
+	doc, _ := client.store.Get("doc")
+	if doc.Permalink != "/name-1/" {
+		t.Errorf("Permalink = %q, want /name-1/", doc.Permalink)
+	}
+	if len(doc.PreviousPermalinks) != 2 {
+		t.Errorf("PreviousPermalinks = %q, want the two other names", doc.PreviousPermalinks)
+	}
+}
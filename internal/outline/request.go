@@ -0,0 +1,189 @@
+package outline
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	maxAttempts    = 5
+	initialBackoff = 500 * time.Millisecond
+	maxBackoff     = 30 * time.Second
+)
+
+// Outline's rate limiter sends RateLimit-Reset as a JavaScript Date string
+const jsDateLayout = "Mon Jan 02 2006 15:04:05 GMT-0700"
+
+func (c *Client) newRequest(ctx context.Context, endpoint string, body []byte) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", c.config().OutlineAPIURL+endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+c.config().OutlineAPIKey)
+	return req, nil
+}
+
+// doRequest posts reqPayload to endpoint until it gets expectedStatus, retrying network
+// errors, 429 and 5xx responses with jittered exponential backoff. The caller must close
+// the body of the returned response.
+func (c *Client) doRequest(ctx context.Context, httpClient *http.Client, endpoint string, reqPayload any, expectedStatus int) (*http.Response, error) {
+	body, err := json.Marshal(reqPayload)
+	if err != nil {
+		return nil, err
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := c.waitForRateLimit(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		req, err := c.newRequest(ctx, endpoint, body)
+		if err != nil {
+			return nil, err
+		}
+
+		var retryAfter time.Duration
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+		} else {
+			c.observeRateLimit(resp)
+			if resp.StatusCode == expectedStatus {
+				return resp, nil
+			}
+
+			err = readAPIError(resp)
+			if !isRetryableStatus(resp.StatusCode) {
+				return nil, err
+			}
+			retryAfter = parseRetryAfter(resp.Header.Get("Retry-After"))
+		}
+
+		if attempt >= maxAttempts {
+			return nil, fmt.Errorf("Giving up on %s after %d attempts - %w", endpoint, attempt, err)
+		}
+
+		delay := backoff(attempt)
+		if retryAfter > delay {
+			delay = retryAfter
+		}
+		log.Printf("Error calling %s, retrying in %v - %v", endpoint, delay.Round(time.Millisecond), err)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func readAPIError(resp *http.Response) error {
+	defer resp.Body.Close()
+
+	var apiErr APIError
+	decoder := json.NewDecoder(resp.Body)
+	err := decoder.Decode(&apiErr)
+	if err != nil {
+		return fmt.Errorf("Unexpected API http status - %d", resp.StatusCode)
+	}
+	return fmt.Errorf("Unexpected API http status - %d, %s", resp.StatusCode, apiErr.Error())
+}
+
+func isRetryableStatus(status int) bool {
+	return status == http.StatusTooManyRequests || status == http.StatusRequestTimeout || status >= 500
+}
+
+func backoff(attempt int) time.Duration {
+	d := initialBackoff << (attempt - 1)
+	if d > maxBackoff || d <= 0 {
+		d = maxBackoff
+	}
+	// Somewhere between half and the full delay, so retries of concurrent calls spread out
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		return time.Duration(seconds * float64(time.Second))
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return time.Until(t)
+	}
+	return 0
+}
+
+func parseRateLimitReset(value string) time.Time {
+	if value == "" {
+		return time.Time{}
+	}
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		// Either a unix timestamp or seconds until the window resets
+		if seconds > 1e9 {
+			return time.Unix(seconds, 0)
+		}
+		return time.Now().Add(time.Duration(seconds) * time.Second)
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return t
+	}
+	if len(value) >= len(jsDateLayout) {
+		if t, err := time.Parse(jsDateLayout, value[:len(jsDateLayout)]); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// observeRateLimit remembers when the rate limit window resets once Outline reports
+// it has been used up, so following calls wait instead of being rejected.
+func (c *Client) observeRateLimit(resp *http.Response) {
+	remaining := resp.Header.Get("RateLimit-Remaining")
+	if remaining != "0" && resp.StatusCode != http.StatusTooManyRequests {
+		return
+	}
+
+	reset := parseRateLimitReset(resp.Header.Get("RateLimit-Reset"))
+	if reset.IsZero() {
+		return
+	}
+	c.rateLimitMu.Lock()
+	if reset.After(c.rateLimitedUntil) {
+		c.rateLimitedUntil = reset
+	}
+	c.rateLimitMu.Unlock()
+}
+
+func (c *Client) waitForRateLimit(ctx context.Context) error {
+	c.rateLimitMu.Lock()
+	wait := time.Until(c.rateLimitedUntil)
+	c.rateLimitMu.Unlock()
+	if wait <= 0 {
+		return nil
+	}
+
+	log.Printf("Outline rate limit reached - Waiting %v", wait.Round(time.Second))
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
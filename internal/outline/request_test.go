@@ -0,0 +1,124 @@
+package outline
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// scriptedOutline answers the requests it gets with the next of its responses, and the
+// last one from then on.
+type scriptedOutline struct {
+	mu        sync.Mutex
+	responses []func(w http.ResponseWriter)
+	requests  []time.Time
+	received  chan struct{}
+}
+
+func newScriptedOutline(responses ...func(w http.ResponseWriter)) *scriptedOutline {
+	return &scriptedOutline{responses: responses, received: make(chan struct{}, 16)}
+}
+
+func (o *scriptedOutline) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	o.mu.Lock()
+	respond := o.responses[min(len(o.requests), len(o.responses)-1)]
+	o.requests = append(o.requests, time.Now())
+	o.mu.Unlock()
+	respond(w)
+	o.received <- struct{}{}
+}
+
+func (o *scriptedOutline) count() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return len(o.requests)
+}
+
+func respondStatus(code int, headers ...string) func(w http.ResponseWriter) {
+	return func(w http.ResponseWriter) {
+		for i := 0; i+1 < len(headers); i += 2 {
+			w.Header().Set(headers[i], headers[i+1])
+		}
+		w.WriteHeader(code)
+		w.Write([]byte(`{"ok":false,"error":"failed","message":"Failed"}`))
+	}
+}
+
+func respondOK(w http.ResponseWriter) {
+	w.Write([]byte(`{"data":{}}`))
+}
+
+func TestRequestRetries(t *testing.T) {
+	tests := []struct {
+		name      string
+		responses []func(w http.ResponseWriter)
+		requests  int
+		err       string
+	}{
+		{"5xx then success", []func(w http.ResponseWriter){respondStatus(http.StatusBadGateway), respondStatus(http.StatusServiceUnavailable), respondOK}, 3, ""},
+		{"non-retryable 4xx", []func(w http.ResponseWriter){respondStatus(http.StatusNotFound), respondOK}, 1, "404"},
+	}
+	for _, tt := range tests {
+		outline := newScriptedOutline(tt.responses...)
+		client, _ := newTestClient(t, outline, nil)
+
+		resp, err := client.doRequest(context.Background(), &http.Client{}, "/documents.info", map[string]string{"id": "doc"}, http.StatusOK)
+		if resp != nil {
+			resp.Body.Close()
+		}
+		if tt.err == "" && err != nil || tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+			t.Errorf("%s: err = %v, want %q", tt.name, err, tt.err)
+		}
+		if got := outline.count(); got != tt.requests {
+			t.Errorf("%s: %d requests, want %d", tt.name, got, tt.requests)
+		}
+	}
+}
+
+func TestRequestWaitsForRetryAfter(t *testing.T) {
+	outline := newScriptedOutline(respondStatus(http.StatusTooManyRequests, "Retry-After", "2"), respondOK)
+	client, _ := newTestClient(t, outline, nil)
+
+	resp, err := client.doRequest(context.Background(), &http.Client{}, "/documents.info", map[string]string{"id": "doc"}, http.StatusOK)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if len(outline.requests) != 2 {
+		t.Fatalf("%d requests, want 2", len(outline.requests))
+	}
+	// Longer than any backoff of the first attempt
+	if waited := outline.requests[1].Sub(outline.requests[0]); waited < 2*time.Second {
+		t.Errorf("retried after %v, want Retry-After of 2s honoured", waited)
+	}
+}
+
+func TestRequestCancelledDuringBackoff(t *testing.T) {
+	outline := newScriptedOutline(respondStatus(http.StatusServiceUnavailable, "Retry-After", "60"))
+	client, _ := newTestClient(t, outline, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		_, err := client.doRequest(ctx, &http.Client{}, "/documents.info", map[string]string{"id": "doc"}, http.StatusOK)
+		done <- err
+	}()
+
+	<-outline.received
+	cancel()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("err = %v, want context.Canceled", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("doRequest kept waiting after the context was cancelled")
+	}
+	if got := outline.count(); got != 1 {
+		t.Errorf("%d requests, want 1", got)
+	}
+}
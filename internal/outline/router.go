@@ -0,0 +1,89 @@
+package outline
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"outline-hexo-connector/internal/config"
+	"sync/atomic"
+)
+
+type EventQueue interface {
+	Enqueue(key string, data []byte) error
+}
+
+// Router takes the webhooks of the Outline instance and hands each event to the client
+// of every site. The sites skip events of collections they do not publish.
+type Router struct {
+	cfg     atomic.Pointer[config.Config]
+	queue   EventQueue
+	clients []*Client
+}
+
+func NewRouter(cfg *config.Config, queue EventQueue, clients []*Client) *Router {
+	r := &Router{
+		queue:   queue,
+		clients: clients,
+	}
+	r.cfg.Store(cfg)
+	return r
+}
+
+// Reconfigure swaps in a reloaded config for the webhooks that follow.
+func (r *Router) Reconfigure(cfg *config.Config) {
+	r.cfg.Store(cfg)
+}
+
+func (r *Router) HandleWebhook(w http.ResponseWriter, req *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, req.Body, 10*1024*1024))
+	if err != nil {
+		log.Printf("Error reading webhook - %v", err)
+		http.Error(w, "Error reading webhook", http.StatusBadRequest)
+		return
+	}
+
+	err = verifyWebhook(r.cfg.Load().OutlineWebhookSecret, body, req.Header.Get("Outline-Signature"))
+	if err != nil {
+		log.Printf("Error verifying webhook - %v", err)
+		http.Error(w, "Error verifying webhook", http.StatusUnauthorized)
+		return
+	}
+
+	webhook, err := parseWebhook(body)
+	if err != nil {
+		log.Printf("Error parsing webhook - %v", err)
+		http.Error(w, "Error parsing webhook", http.StatusBadRequest)
+		return
+	}
+
+	// Only acknowledge once the event is safely stored, Outline retries otherwise
+	err = r.queue.Enqueue(webhook.Payload.Model.ID, body)
+	if err != nil {
+		log.Printf("Error queueing webhook - %v", err)
+		http.Error(w, "Error queueing webhook", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("Acknowledged"))
+}
+
+// ProcessWebhook handles a verified webhook body taken from the event queue. The errors
+// of all sites are returned together, so the event is retried for the ones that failed.
+func (r *Router) ProcessWebhook(ctx context.Context, body []byte) error {
+	var errs []error
+	for _, c := range r.clients {
+		// Every site fills in the document on its own copy
+		webhook, err := parseWebhook(body)
+		if err != nil {
+			return err
+		}
+		err = c.handleEvent(ctx, webhook)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
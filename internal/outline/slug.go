@@ -0,0 +1,100 @@
+package outline
+
+import (
+	"fmt"
+	"outline-hexo-connector/internal/config"
+	"outline-hexo-connector/internal/generator"
+	"regexp"
+	"strings"
+)
+
+var documentIDRe = regexp.MustCompile(`^[a-f0-9]{8}-[a-f0-9]{4}-[a-f0-9]{4}-[a-f0-9]{4}-[a-f0-9]{12}$`)
+
+// currentPostName returns the name the post of document id was last written under.
+func (c *Client) currentPostName(id string) string {
+	if doc, ok := c.store.Get(id); ok && doc.FileName != "" {
+		return doc.FileName
+	}
+	return id
+}
+
+// postName returns the name the post of doc should be written under. With slug file
+// names, a slug already used by another document or by a post not written by the
+// connector gets a numeric suffix.
+func (c *Client) postName(doc *DocumentPayload) string {
+	c.postNamesMu.Lock()
+	defer c.postNamesMu.Unlock()
+	return c.allocatePostName(doc)
+}
+
+// reservePostName returns the name of postName and keeps it for doc until
+// releasePostName, so documents written at the same time never get the same name.
+func (c *Client) reservePostName(doc *DocumentPayload) string {
+	c.postNamesMu.Lock()
+	defer c.postNamesMu.Unlock()
+
+	name := c.allocatePostName(doc)
+	for reserved, id := range c.reservedPostNames {
+		if id == doc.ID {
+			delete(c.reservedPostNames, reserved)
+		}
+	}
+	c.reservedPostNames[name] = doc.ID
+	return name
+}
+
+// releasePostName drops the reservation of document id, once its state records the name.
+func (c *Client) releasePostName(id string) {
+	c.postNamesMu.Lock()
+	defer c.postNamesMu.Unlock()
+	for reserved, owner := range c.reservedPostNames {
+		if owner == id {
+			delete(c.reservedPostNames, reserved)
+		}
+	}
+}
+
+// allocatePostName does the work of postName, with postNamesMu held.
+func (c *Client) allocatePostName(doc *DocumentPayload) string {
+	if c.config().PostFileName != config.PostFileNameSlug {
+		return doc.ID
+	}
+	base := generator.Slugify(doc.Title)
+	if base == "" {
+		return doc.ID
+	}
+
+	current := c.currentPostName(doc.ID)
+	taken := func(name string) bool {
+		if id, ok := c.reservedPostNames[name]; ok {
+			return id != doc.ID
+		}
+		if id, ok := c.store.Owner(name); ok {
+			return id != doc.ID
+		}
+		return name != current && c.generator.PostExists(name)
+	}
+
+	// Keep a suffixed name as long as it still belongs to the same title
+	if (current == base || isSuffixedSlug(current, base)) && !taken(current) {
+		return current
+	}
+	name := base
+	for i := 2; taken(name); i++ {
+		name = fmt.Sprintf("%s-%d", base, i)
+	}
+	return name
+}
+
+func isSuffixedSlug(name string, base string) bool {
+	suffix, found := strings.CutPrefix(name, base+"-")
+	if !found || suffix == "" {
+		return false
+	}
+	for _, r := range suffix {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,80 @@
+package outline
+
+import (
+	"context"
+	"fmt"
+	"outline-hexo-connector/internal/config"
+	"outline-hexo-connector/internal/generator"
+	"sync"
+	"testing"
+)
+
+func TestConcurrentPostNamesAreUnique(t *testing.T) {
+	client, gen := newTestClient(t, &fakeOutline{}, func(cfg *config.Config) {
+		cfg.PostFileName = config.PostFileNameSlug
+	})
+	// Written by hand, not by the connector
+	gen.posts["hello-world"] = &generator.Post{Name: "hello-world"}
+
+	const count = 20
+	var wg sync.WaitGroup
+	for i := 0; i < count; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			doc := &DocumentPayload{ID: fmt.Sprintf("doc%d", i), Title: "Hello World", Text: "Hello"}
+			if err := client.writePost(context.Background(), doc); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	names := make(map[string]string)
+	for i := 0; i < count; i++ {
+		id := fmt.Sprintf("doc%d", i)
+		doc, _ := client.store.Get(id)
+		if doc.FileName == "" || doc.FileName == "hello-world" {
+			t.Errorf("%s written as %q", id, doc.FileName)
+		}
+		if other, ok := names[doc.FileName]; ok {
+			t.Errorf("%s and %s both written as %q", id, other, doc.FileName)
+		}
+		names[doc.FileName] = id
+		if owner, _ := client.store.Owner(doc.FileName); owner != id {
+			t.Errorf("owner of %q = %q, want %s", doc.FileName, owner, id)
+		}
+	}
+	if len(gen.posts) != count+1 {
+		t.Errorf("%d posts, want %d", len(gen.posts), count+1)
+	}
+	if len(client.reservedPostNames) != 0 {
+		t.Errorf("names still reserved after writing - %v", client.reservedPostNames)
+	}
+}
+
+func TestPostNameKeepsSuffix(t *testing.T) {
+	client, _ := newTestClient(t, &fakeOutline{}, func(cfg *config.Config) {
+		cfg.PostFileName = config.PostFileNameSlug
+	})
+	ctx := context.Background()
+	first := &DocumentPayload{ID: "first", Title: "Same", Text: "Hello"}
+	second := &DocumentPayload{ID: "second", Title: "Same", Text: "Hello"}
+	for _, doc := range []*DocumentPayload{first, second, second, first} {
+		if err := client.writePost(ctx, doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if name := client.currentPostName("first"); name != "same" {
+		t.Errorf("first = %q, want same", name)
+	}
+	if name := client.currentPostName("second"); name != "same-2" {
+		t.Errorf("second = %q, want same-2", name)
+	}
+
+	second.Title = "Other"
+	if name := client.postName(second); name != "other" {
+		t.Errorf("renamed second = %q, want other", name)
+	}
+}
@@ -0,0 +1,109 @@
+package outline
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+)
+
+const listPageSize = 100
+
+// listBlogDocuments returns every published child document of the blog collections,
+// with Categories and Collection filled in.
+func (c *Client) listBlogDocuments(ctx context.Context) ([]*DocumentPayload, error) {
+	collections, err := c.findBlogCollections(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return c.listDocumentsOf(ctx, collections)
+}
+
+// listDocumentsOf returns every published child document of collections.
+func (c *Client) listDocumentsOf(ctx context.Context, collections []CollectionPayload) ([]*DocumentPayload, error) {
+	var result []*DocumentPayload
+	for i := range collections {
+		documents, err := c.listCollectionDocuments(ctx, &collections[i])
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, documents...)
+	}
+	return result, nil
+}
+
+// listCollectionDocuments returns every published child document of collection.
+func (c *Client) listCollectionDocuments(ctx context.Context, collection *CollectionPayload) ([]*DocumentPayload, error) {
+	var all []DocumentPayload
+	for offset := 0; ; offset += listPageSize {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		documents, err := c.ListDocuments(ctx, collection.ID, offset, listPageSize)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, documents...)
+
+		if len(documents) < listPageSize {
+			break
+		}
+	}
+
+	parents := make(map[string]*DocumentPayload, len(all))
+	for i := range all {
+		parents[all[i].ID] = &all[i]
+	}
+
+	var result []*DocumentPayload
+	for i := range all {
+		doc := &all[i]
+		if doc.PublishedAt == "" || !c.isPostDocument(doc) {
+			continue
+		}
+
+		categories, err := c.categoriesOf(ctx, doc, parents)
+		if err != nil {
+			return nil, fmt.Errorf("Error fetching parent document info of %s - %w", doc.ID, err)
+		}
+		doc.Categories = categories
+		doc.Collection = collection
+		result = append(result, doc)
+	}
+	return result, nil
+}
+
+// Sync writes every published child document of the blog collections into the site sources.
+// It does not trigger a build, the caller decides when to build.
+func (c *Client) Sync(ctx context.Context) (int, error) {
+	collections, err := c.findBlogCollections(ctx)
+	if err != nil {
+		return 0, err
+	}
+	documents, err := c.listDocumentsOf(ctx, collections)
+	if err != nil {
+		return 0, err
+	}
+	names := make([]string, len(collections))
+	for i, collection := range collections {
+		names[i] = collection.Name
+	}
+	log.Printf("Syncing %d documents from collection %s", len(documents), strings.Join(names, ", "))
+
+	synced := 0
+	for _, doc := range documents {
+		if err := ctx.Err(); err != nil {
+			return synced, err
+		}
+
+		err := c.writePost(ctx, doc)
+		if err != nil {
+			log.Printf("Error creating post for %s - %v", doc.ID, err)
+			continue
+		}
+		synced++
+	}
+
+	return synced, nil
+}
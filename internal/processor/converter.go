@@ -1,40 +1,64 @@
 package processor
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"regexp"
 )
 
 type AttachmentUrlProvider interface {
-	GetAttachmentUrl(attachmentID string) (string, error)
+	GetAttachmentUrl(ctx context.Context, attachmentID string) (string, error)
 }
 
-func ConvertAttachmentUrl(provider AttachmentUrlProvider, text string) (string, error) {
-	// Some regex magic to find outline attachment urls
-	re := regexp.MustCompile(`(?P<prefix>!?)\[(?P<text>.*?)\]\(/api/attachments\.redirect\?id=(?P<id>[a-f0-9-]{36})(?P<extra>.*?)\)`)
+var (
+	attachmentRe   = regexp.MustCompile(`^/api/attachments\.redirect\?id=([a-f0-9-]{36})`)
+	attachmentSize = regexp.MustCompile(`\s+(\d+x\d+|\d+)$`)
+	imageSizeHint  = regexp.MustCompile(`\s+\\"=?\d*x?\d*\\"`)
+)
 
-	newText := re.ReplaceAllStringFunc(text, func(match string) string {
-		submatches := re.FindStringSubmatch(match)
-		prefix := submatches[1]
-		text := submatches[2]
-		id := submatches[3]
+// ConvertAttachments drops Outline's image size hints and points attachment links and
+// images at the URLs given by env.Attachments.
+func ConvertAttachments(ctx context.Context, env *Env, doc *Document, result *MetadataAndText) error {
+	// Size hints first, with their escaped quotes the images are not parsed as images
+	source := doc.Source()
+	for _, line := range doc.InlineLines() {
+		for _, r := range line.Ranges {
+			for _, m := range imageSizeHint.FindAllIndex(source[r[0]:r[1]], -1) {
+				doc.Replace(r[0]+m[0], r[0]+m[1], "")
+			}
+		}
+	}
+	if env.Attachments == nil {
+		return nil
+	}
+	doc.Commit()
 
-		rawUrl, err := provider.GetAttachmentUrl(id)
+	source = doc.Source()
+	for _, link := range doc.Links() {
+		match := attachmentRe.FindStringSubmatch(link.Destination())
+		if match == nil {
+			continue
+		}
+		id := match[1]
+
+		rawUrl, err := env.Attachments.GetAttachmentUrl(ctx, id)
 		if err != nil {
 			log.Printf("Error getting attachment OSS URL - %v", err)
-			return match
+			continue
 		}
 
-		textCleanup := regexp.MustCompile(`\s+(\d+x\d+|\d+)$`)
-		cleanText := textCleanup.ReplaceAllString(text, "")
-
-		return fmt.Sprintf("%s[%s](%s)", prefix, cleanText, rawUrl)
-	})
-
-	reExtra := regexp.MustCompile(`\s+\\"=?\d*x?\d*\\"`)
-	text = reExtra.ReplaceAllString(newText, "")
-
-	return text, nil
+		if result.Attachments == nil {
+			result.Attachments = make(map[string]string)
+		}
+		result.Attachments[id] = rawUrl
 
+		// Only the size after the text and the destination are replaced, so an image
+		// inside the text of a link is converted as well
+		if m := attachmentSize.FindIndex(source[link.TextStart:link.TextStop]); m != nil {
+			doc.Replace(link.TextStart+m[0], link.TextStart+m[1], "")
+		}
+		doc.Replace(link.TextStop, link.Stop, fmt.Sprintf("](%s)", rawUrl))
+	}
+	return nil
 }
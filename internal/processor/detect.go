@@ -0,0 +1,48 @@
+package processor
+
+import (
+	"bytes"
+	"context"
+	"regexp"
+
+	"github.com/yuin/goldmark/ast"
+)
+
+var (
+	// $...$ without space inside the dollars and no letter or digit right outside them, so
+	// prices like $5 and $10 and shell variables like $HOME/$USER are not math
+	inlineMathRe = regexp.MustCompile(`(?:^|[^\\$\w])\$[^\s$](?:[^$]*?[^\s$\\])?\$(?:[^\w$]|$)`)
+	blockMathRe  = regexp.MustCompile(`(?:^|[^\\])\$\$`)
+)
+
+// DetectMath marks documents with $...$ or $$...$$ math so only their posts load KaTeX.
+func DetectMath(ctx context.Context, env *Env, doc *Document, result *MetadataAndText) error {
+	source := doc.Source()
+	for _, line := range doc.InlineLines() {
+		for _, r := range line.Ranges {
+			part := source[r[0]:r[1]]
+			if !bytes.ContainsRune(part, '$') {
+				continue
+			}
+			if blockMathRe.Match(part) || inlineMathRe.Match(part) {
+				result.Math = true
+				return nil
+			}
+		}
+	}
+	return nil
+}
+
+// DetectMermaid marks documents with mermaid code blocks so only their posts load Mermaid.
+func DetectMermaid(ctx context.Context, env *Env, doc *Document, result *MetadataAndText) error {
+	ast.Walk(doc.root, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if block, ok := n.(*ast.FencedCodeBlock); ok && entering {
+			if string(block.Language(doc.source)) == "mermaid" {
+				result.Mermaid = true
+				return ast.WalkStop, nil
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+	return nil
+}
@@ -0,0 +1,110 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"outline-hexo-connector/internal/config"
+	"regexp"
+	"strings"
+)
+
+// Targets of links to other documents
+const (
+	DocLinkPermalink = "permalink"
+	DocLinkPostLink  = "post_link"
+)
+
+// LinkedPost is the post a link to another Outline document points at.
+type LinkedPost struct {
+	Name      string // File name of the post without extension
+	Permalink string // URL path of the post, empty when unknown
+}
+
+// DocumentLinkResolver looks up the posts of linked Outline documents.
+type DocumentLinkResolver interface {
+	// ResolveDocumentLink returns the post of the document with the URL ID urlID, or false
+	// when the document is not published in the blog.
+	ResolveDocumentLink(ctx context.Context, urlID string) (LinkedPost, bool, error)
+}
+
+// Destinations of links to a document path, the URL ID is the last 10 characters of the path segment
+var docLinkRe = regexp.MustCompile(`^((?:https?://[^/\s]+)?/doc/[^/\s#?]*?([A-Za-z0-9]{10}))([#?]\S*)?$`)
+
+// docLinkStage points links between documents at the posts of the linked documents.
+type docLinkStage struct {
+	Target      string `yaml:"target"`
+	Unpublished string `yaml:"unpublished"`
+}
+
+func newDocLinkStage(cfg config.StageConfig) (Stage, error) {
+	stage := &docLinkStage{
+		Target:      DocLinkPermalink,
+		Unpublished: "strip",
+	}
+	if err := cfg.Decode(stage); err != nil {
+		return nil, err
+	}
+	if stage.Target != DocLinkPermalink && stage.Target != DocLinkPostLink {
+		return nil, fmt.Errorf("Invalid target %q, expected %s or %s", stage.Target, DocLinkPermalink, DocLinkPostLink)
+	}
+	if stage.Unpublished != "strip" && stage.Unpublished != TargetKeep {
+		return nil, fmt.Errorf("Invalid unpublished %q, expected strip or %s", stage.Unpublished, TargetKeep)
+	}
+	return stage, nil
+}
+
+func (s *docLinkStage) Process(ctx context.Context, env *Env, doc *Document, result *MetadataAndText) error {
+	if env.Documents == nil {
+		return nil
+	}
+
+	for _, link := range doc.Links() {
+		if link.IsImage() {
+			continue
+		}
+		match := docLinkRe.FindStringSubmatch(link.Destination())
+		if match == nil || !isOutlineURL(match[1], env.OutlineURL) {
+			continue
+		}
+		target, urlID := match[1], match[2]
+		var fragment string
+		if strings.HasPrefix(match[3], "#") {
+			fragment = match[3]
+		}
+
+		replacement, ok := s.rewrite(ctx, env, result, doc.Text(link), target, urlID, fragment)
+		if ok {
+			doc.Replace(link.Start, link.Stop, replacement)
+		}
+	}
+	return nil
+}
+
+func isOutlineURL(target string, outlineURL string) bool {
+	if strings.HasPrefix(target, "/") {
+		return true
+	}
+	return outlineURL != "" && strings.HasPrefix(target, strings.TrimSuffix(outlineURL, "/")+"/")
+}
+
+// rewrite returns what the link with text to target is replaced with, false to keep it.
+func (s *docLinkStage) rewrite(ctx context.Context, env *Env, result *MetadataAndText, text, target, urlID, fragment string) (string, bool) {
+	post, found, err := env.Documents.ResolveDocumentLink(ctx, urlID)
+	if err != nil {
+		result.Warnf("Error resolving link to %s - %v", target, err)
+		return "", false
+	}
+	if !found {
+		result.Warnf("Link to %s which is not published in the blog", target)
+		if s.Unpublished == "strip" {
+			return text, true
+		}
+		return "", false
+	}
+
+	// Without Redirect_Permalink the permalink is unknown, Hexo resolves post_link itself
+	if s.Target == DocLinkPostLink || post.Permalink == "" {
+		return fmt.Sprintf(`{%% post_link %s "%s" %%}`, post.Name, strings.ReplaceAll(text, `"`, "&quot;")), true
+	}
+	return fmt.Sprintf("[%s](%s%s)", text, post.Permalink, fragment), true
+}
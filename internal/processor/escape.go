@@ -0,0 +1,29 @@
+package processor
+
+import (
+	"context"
+	"regexp"
+	"strings"
+)
+
+var newlineRe = regexp.MustCompile(`([^\x00]|^)(\\n|\\\n)`)
+
+// UnescapeNewlines turns the escaped line breaks Outline sends into real ones. Code is
+// left as is.
+func UnescapeNewlines(ctx context.Context, env *Env, doc *Document, result *MetadataAndText) error {
+	source := doc.Source()
+	for _, r := range doc.NonCodeRanges() {
+		text := string(source[r[0]:r[1]])
+		if rendered := renderNewline(text); rendered != text {
+			doc.Replace(r[0], r[1], rendered)
+		}
+	}
+	return nil
+}
+
+func renderNewline(text string) string {
+	text = strings.ReplaceAll(text, "\\\\", "\x00")
+	text = newlineRe.ReplaceAllString(text, "$1\n")
+	text = strings.ReplaceAll(text, "\x00", "\\\\")
+	return text
+}
@@ -0,0 +1,147 @@
+package processor
+
+import (
+	"bytes"
+	"context"
+	"outline-hexo-connector/internal/config"
+	"outline-hexo-connector/internal/generator"
+	"regexp"
+
+	"github.com/yuin/goldmark/ast"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultFrontMatterKeys are the fields documents may set when allowed_keys is not configured.
+var DefaultFrontMatterKeys = []string{"excerpt", "sticky", "comments", "password", "layout", "lang"}
+
+var (
+	metaRe           = regexp.MustCompile(`^\\?\+>\s*Meta:\s*$`)
+	fieldRe          = regexp.MustCompile(`^[A-Za-z_][\w-]*:(\s|$)`)
+	hardBreakRe      = regexp.MustCompile(`\\(\r?\n|n)`)
+	markdownEscapeRe = regexp.MustCompile("\\\\([!-/:-@\\[-`{-~])")
+)
+
+// frontMatterStage takes a block of YAML front matter fields out of the document. The
+// block is a frontmatter code block, or a paragraph starting with a +> Meta: line along
+// with the field paragraphs following it, and has to be the first block of the document.
+type frontMatterStage struct {
+	allowed map[string]bool
+}
+
+func newFrontMatterStage(cfg config.StageConfig) (Stage, error) {
+	options := struct {
+		AllowedKeys []string `yaml:"allowed_keys"`
+	}{
+		AllowedKeys: DefaultFrontMatterKeys,
+	}
+	if err := cfg.Decode(&options); err != nil {
+		return nil, err
+	}
+
+	stage := &frontMatterStage{allowed: make(map[string]bool)}
+	for _, key := range options.AllowedKeys {
+		stage.allowed[key] = true
+	}
+	return stage, nil
+}
+
+func (s *frontMatterStage) Process(ctx context.Context, env *Env, doc *Document, result *MetadataAndText) error {
+	n := doc.root.FirstChild()
+	blocks := []ast.Node{n}
+	var fields []byte
+	switch block := n.(type) {
+	case *ast.FencedCodeBlock:
+		if string(block.Language(doc.source)) != "frontmatter" {
+			return nil
+		}
+		segments := block.Lines()
+		for i := 0; i < segments.Len(); i++ {
+			fields = append(fields, doc.segment(segments.At(i))...)
+		}
+	case *ast.Paragraph:
+		lines := paragraphLines(doc, block)
+		if !metaRe.Match(bytes.TrimSpace(lines[0])) {
+			return nil
+		}
+		fields = bytes.Join(lines[1:], []byte("\n"))
+		// Outline writes every field typed on its own line as a paragraph of its own
+		for next := n.NextSibling(); next != nil; next = next.NextSibling() {
+			paragraph, ok := next.(*ast.Paragraph)
+			if !ok || !isFieldParagraph(doc, paragraph) {
+				break
+			}
+			fields = append(fields, '\n')
+			fields = append(fields, bytes.Join(paragraphLines(doc, paragraph), []byte("\n"))...)
+			blocks = append(blocks, next)
+		}
+	default:
+		return nil
+	}
+
+	if s.merge(fields, result) {
+		for _, block := range blocks {
+			doc.RemoveBlock(block)
+		}
+	}
+	return nil
+}
+
+// paragraphLines returns the lines of a paragraph with the hard breaks and Markdown
+// escapes Outline writes undone.
+func paragraphLines(doc *Document, paragraph *ast.Paragraph) [][]byte {
+	var text []byte
+	segments := paragraph.Lines()
+	for i := 0; i < segments.Len(); i++ {
+		text = append(text, doc.segment(segments.At(i))...)
+	}
+	text = hardBreakRe.ReplaceAll(text, []byte("\n"))
+	text = markdownEscapeRe.ReplaceAll(text, []byte("$1"))
+	return bytes.Split(bytes.TrimRight(text, "\r\n"), []byte("\n"))
+}
+
+// isFieldParagraph reports whether every line of paragraph is a key: value field.
+func isFieldParagraph(doc *Document, paragraph *ast.Paragraph) bool {
+	for _, line := range paragraphLines(doc, paragraph) {
+		if !fieldRe.Match(line) {
+			return false
+		}
+	}
+	return true
+}
+
+// merge adds the allowed fields of the YAML mapping in fields to the front matter of result.
+func (s *frontMatterStage) merge(fields []byte, result *MetadataAndText) bool {
+	var node yaml.Node
+	if err := yaml.Unmarshal(fields, &node); err != nil {
+		result.Warnf("Front matter block is not valid YAML - %v", err)
+		return false
+	}
+	if len(node.Content) == 0 || node.Content[0].Kind == yaml.MappingNode && len(node.Content[0].Content) == 0 {
+		result.Warnf("Front matter block has no fields - Keeping it in the body")
+		return false
+	}
+	mapping := node.Content[0]
+	if mapping.Kind != yaml.MappingNode {
+		result.Warnf("Front matter block is not a YAML mapping")
+		return false
+	}
+
+	if result.FrontMatter == nil {
+		result.FrontMatter = generator.NewFrontMatter()
+	}
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		key := mapping.Content[i].Value
+		if !s.allowed[key] {
+			result.Warnf("Front matter field %s is not allowed - Skipping", key)
+			continue
+		}
+		var value any
+		if err := mapping.Content[i+1].Decode(&value); err != nil {
+			result.Warnf("Front matter field %s is invalid - %v", key, err)
+			continue
+		}
+		result.FrontMatter.Set(key, value)
+		result.AddDirective("Front matter: %s", key)
+	}
+	return true
+}
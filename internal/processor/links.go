@@ -0,0 +1,73 @@
+package processor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"outline-hexo-connector/internal/config"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+type linkRewrite struct {
+	re   *regexp.Regexp
+	from string
+	to   string
+}
+
+// linkStage rewrites the start of link and image destinations.
+type linkStage struct {
+	rewrites []linkRewrite
+}
+
+func newLinkStage(cfg config.StageConfig) (Stage, error) {
+	var options struct {
+		Rewrite map[string]string `yaml:"rewrite"`
+	}
+	if err := cfg.Decode(&options); err != nil {
+		return nil, err
+	}
+	if len(options.Rewrite) == 0 {
+		return nil, fmt.Errorf("No rewrite prefixes configured")
+	}
+
+	// Longest prefix first, so more specific rules win
+	prefixes := make([]string, 0, len(options.Rewrite))
+	for from := range options.Rewrite {
+		prefixes = append(prefixes, from)
+	}
+	sort.Slice(prefixes, func(i, j int) bool {
+		return len(prefixes[i]) > len(prefixes[j])
+	})
+
+	stage := &linkStage{}
+	for _, from := range prefixes {
+		stage.rewrites = append(stage.rewrites, linkRewrite{
+			re:   regexp.MustCompile(`^\]\(\s*<?(` + regexp.QuoteMeta(from) + `)`),
+			from: from,
+			to:   options.Rewrite[from],
+		})
+	}
+	return stage, nil
+}
+
+func (s *linkStage) Process(ctx context.Context, env *Env, doc *Document, result *MetadataAndText) error {
+	source := doc.Source()
+	for _, link := range doc.Links() {
+		tail := source[link.TextStop:link.Stop]
+		for _, rewrite := range s.rewrites {
+			if m := rewrite.re.FindSubmatchIndex(tail); m != nil {
+				doc.Replace(link.TextStop+m[2], link.TextStop+m[3], rewrite.to)
+				break
+			}
+			// Reference links keep their destination in the definition, they become inline links
+			destination := link.Destination()
+			if !bytes.HasPrefix(tail, []byte("](")) && strings.HasPrefix(destination, rewrite.from) {
+				doc.Replace(link.TextStop, link.Stop, fmt.Sprintf("](<%s%s>)", rewrite.to, destination[len(rewrite.from):]))
+				break
+			}
+		}
+	}
+	return nil
+}
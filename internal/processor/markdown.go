@@ -0,0 +1,421 @@
+package processor
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	east "github.com/yuin/goldmark/extension/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+)
+
+var markdownParser parser.Parser = goldmark.New(
+	goldmark.WithParser(newMarkdownParser()),
+	goldmark.WithExtensions(extension.GFM),
+).Parser()
+
+// newMarkdownParser returns goldmark's default parser, with the link parser wrapped so
+// the source ranges of links and images are known.
+func newMarkdownParser() parser.Parser {
+	inlineParsers := parser.DefaultInlineParsers()
+	for i, p := range inlineParsers {
+		if inline, ok := p.Value.(parser.InlineParser); ok && bytes.IndexByte(inline.Trigger(), ']') != -1 {
+			inlineParsers[i].Value = linkRangeParser{inline}
+		}
+	}
+	return parser.NewParser(
+		parser.WithBlockParsers(parser.DefaultBlockParsers()...),
+		parser.WithInlineParsers(inlineParsers...),
+		parser.WithParagraphTransformers(parser.DefaultParagraphTransformers()...),
+	)
+}
+
+var linkRangesKey = parser.NewContextKey()
+
+type linkRanges struct {
+	open   []int // Offsets of the [ and ![ not closed yet, innermost last
+	ranges map[ast.Node]Link
+}
+
+// linkRangeParser records where the links and images made by the wrapped link parser
+// start and end. It follows the open brackets the same way the link parser does, every ]
+// closes the innermost one whether or not it makes a link.
+type linkRangeParser struct {
+	parser.InlineParser
+}
+
+func (p linkRangeParser) Parse(parent ast.Node, block text.Reader, pc parser.Context) ast.Node {
+	ranges, _ := pc.Get(linkRangesKey).(*linkRanges)
+	if ranges == nil {
+		ranges = &linkRanges{ranges: make(map[ast.Node]Link)}
+		pc.Set(linkRangesKey, ranges)
+	}
+
+	line, segment := block.PeekLine()
+	node := p.InlineParser.Parse(parent, block, pc)
+	if line[0] != ']' {
+		if node != nil {
+			ranges.open = append(ranges.open, segment.Start)
+		}
+		return node
+	}
+	if len(ranges.open) == 0 {
+		return node
+	}
+	start := ranges.open[len(ranges.open)-1]
+	ranges.open = ranges.open[:len(ranges.open)-1]
+	if node != nil {
+		_, after := block.Position()
+		link := Link{Node: node, Start: start, TextStart: start + 1, TextStop: segment.Start, Stop: after.Start}
+		if _, ok := node.(*ast.Image); ok {
+			link.TextStart++
+		}
+		ranges.ranges[node] = link
+	}
+	return node
+}
+
+func (p linkRangeParser) CloseBlock(parent ast.Node, block text.Reader, pc parser.Context) {
+	if ranges, ok := pc.Get(linkRangesKey).(*linkRanges); ok {
+		ranges.open = nil
+	}
+	if closer, ok := p.InlineParser.(parser.CloseBlocker); ok {
+		closer.CloseBlock(parent, block, pc)
+	}
+}
+
+// Document is Markdown source together with its GFM syntax tree. Stages look at the
+// tree and record edits against the source, String prints the source back with the edits
+// applied, so everything no transform touched stays byte for byte the same.
+type Document struct {
+	source []byte
+	root   ast.Node
+	links  map[ast.Node]Link
+	edits  []edit
+}
+
+// Link is a link or image of the document and where it is written. A reference link
+// ends after its label, the definition stays where it is.
+type Link struct {
+	Node      ast.Node // *ast.Link or *ast.Image
+	Start     int      // Offset of the [ or ![
+	TextStart int      // Offset of the link text
+	TextStop  int      // Offset of the ] after the link text
+	Stop      int      // Offset after the ) or the reference label
+}
+
+func (l Link) IsImage() bool {
+	_, ok := l.Node.(*ast.Image)
+	return ok
+}
+
+// Destination returns the destination with escapes resolved, from the definition for
+// reference links.
+func (l Link) Destination() string {
+	switch n := l.Node.(type) {
+	case *ast.Link:
+		return string(n.Destination)
+	case *ast.Image:
+		return string(n.Destination)
+	}
+	return ""
+}
+
+type edit struct {
+	start int
+	stop  int
+	text  string
+}
+
+// Line is a line of inline content, such as a paragraph or heading line, outside of code.
+type Line struct {
+	Start int // Offset of the content, after any indentation or container markers
+	Stop  int // Offset of the end of the content, without the line break
+	// Ranges are the parts of the line outside code spans and raw HTML
+	Ranges [][2]int
+}
+
+func Parse(markdown string) *Document {
+	source := []byte(markdown)
+	pc := parser.NewContext()
+	doc := &Document{
+		source: source,
+		root:   markdownParser.Parse(text.NewReader(source), parser.WithContext(pc)),
+	}
+	if ranges, ok := pc.Get(linkRangesKey).(*linkRanges); ok {
+		doc.links = ranges.ranges
+	}
+	return doc
+}
+
+// Links returns the links and images of the document in order, an image inside the text
+// of a link after the link.
+func (d *Document) Links() []Link {
+	var links []Link
+	ast.Walk(d.root, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if link, ok := d.links[n]; ok && entering {
+			links = append(links, link)
+		}
+		return ast.WalkContinue, nil
+	})
+	return links
+}
+
+// Text returns the source of the text of link.
+func (d *Document) Text(link Link) string {
+	return string(d.source[link.TextStart:link.TextStop])
+}
+
+func (d *Document) Source() []byte {
+	return d.source
+}
+
+func (d *Document) segment(segment text.Segment) []byte {
+	return d.source[segment.Start:segment.Stop]
+}
+
+// Replace records that source[start:stop] is replaced with text.
+func (d *Document) Replace(start, stop int, text string) {
+	d.edits = append(d.edits, edit{start: start, stop: stop, text: text})
+}
+
+// RemoveLine records that line is removed entirely. A blank line following a line that
+// stood on its own is removed too, so no gap is left behind. When the line shares its
+// source line with other content, such as a table cell, only its content is removed.
+func (d *Document) RemoveLine(line Line) {
+	start := bytes.LastIndexByte(d.source[:line.Start], '\n') + 1
+	stop := d.nextLineStart(line.Stop)
+	if len(bytes.Trim(d.source[start:line.Start], " \t>")) > 0 || !d.isBlankLine(line.Stop, stop) {
+		d.Replace(line.Start, line.Stop, "")
+		return
+	}
+	d.removeLines(start, stop)
+}
+
+// RemoveBlock records that a top level block is removed, along with a blank line after it.
+func (d *Document) RemoveBlock(block ast.Node) {
+	start, stop := d.blockRange(block)
+	if start == -1 {
+		return
+	}
+	d.removeLines(start, stop)
+}
+
+// removeLines removes the whole lines from start to stop, and the blank line following
+// them when they stood on their own.
+func (d *Document) removeLines(start, stop int) {
+	if start == 0 || d.isBlankLine(d.previousLineStart(start), start) {
+		if next := d.nextLineStart(stop); stop < len(d.source) && d.isBlankLine(stop, next) {
+			stop = next
+		}
+	}
+	d.Replace(start, stop, "")
+}
+
+// blockRange returns the whole lines a block is written on, -1 when it has no lines.
+func (d *Document) blockRange(block ast.Node) (int, int) {
+	segments := block.Lines()
+	fenced, isFenced := block.(*ast.FencedCodeBlock)
+
+	var start, stop int
+	switch {
+	case isFenced && fenced.Info != nil:
+		start, stop = fenced.Info.Segment.Start, fenced.Info.Segment.Stop
+	case segments.Len() > 0:
+		start, stop = segments.At(0).Start, segments.At(0).Start
+	default:
+		return -1, -1
+	}
+	if segments.Len() > 0 {
+		stop = segments.At(segments.Len() - 1).Stop
+	}
+	start = bytes.LastIndexByte(d.source[:start], '\n') + 1
+	if stop == 0 || d.source[stop-1] != '\n' {
+		stop = d.nextLineStart(stop)
+	}
+
+	if isFenced {
+		// The fences are not part of the lines
+		if fenced.Info == nil {
+			start = d.previousLineStart(start)
+		}
+		next := d.nextLineStart(stop)
+		fence := bytes.TrimSpace(d.source[stop:next])
+		if bytes.HasPrefix(fence, []byte("```")) || bytes.HasPrefix(fence, []byte("~~~")) {
+			stop = next
+		}
+	}
+	return start, stop
+}
+
+func (d *Document) nextLineStart(pos int) int {
+	if i := bytes.IndexByte(d.source[pos:], '\n'); i != -1 {
+		return pos + i + 1
+	}
+	return len(d.source)
+}
+
+func (d *Document) previousLineStart(lineStart int) int {
+	if lineStart == 0 {
+		return 0
+	}
+	return bytes.LastIndexByte(d.source[:lineStart-1], '\n') + 1
+}
+
+func (d *Document) isBlankLine(start, stop int) bool {
+	return len(bytes.TrimSpace(d.source[start:stop])) == 0
+}
+
+func (d *Document) String() string {
+	edits := make([]edit, len(d.edits))
+	copy(edits, d.edits)
+	sort.SliceStable(edits, func(i, j int) bool {
+		return edits[i].start < edits[j].start
+	})
+
+	var b strings.Builder
+	pos := 0
+	for _, e := range edits {
+		if e.start < pos {
+			// Overlaps an earlier edit
+			continue
+		}
+		b.Write(d.source[pos:e.start])
+		b.WriteString(e.text)
+		pos = e.stop
+	}
+	b.Write(d.source[pos:])
+	return b.String()
+}
+
+// Commit applies the recorded edits and parses the result again.
+func (d *Document) Commit() {
+	if len(d.edits) == 0 {
+		return
+	}
+	*d = *Parse(d.String())
+}
+
+func holdsInlineContent(n ast.Node) bool {
+	switch n.Kind() {
+	case ast.KindParagraph, ast.KindHeading, ast.KindTextBlock, east.KindTableCell:
+		return true
+	}
+	return false
+}
+
+// InlineLines returns every line of paragraphs, headings, list items and table cells.
+func (d *Document) InlineLines() []Line {
+	var lines []Line
+	ast.Walk(d.root, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering || n.Type() != ast.TypeBlock || !holdsInlineContent(n) {
+			return ast.WalkContinue, nil
+		}
+
+		protected := d.inlineCode(n)
+		segments := n.Lines()
+		for i := 0; i < segments.Len(); i++ {
+			segment := segments.At(i)
+			stop := segment.Stop
+			for stop > segment.Start && (d.source[stop-1] == '\n' || d.source[stop-1] == '\r') {
+				stop--
+			}
+			lines = append(lines, Line{
+				Start:  segment.Start,
+				Stop:   stop,
+				Ranges: subtract([][2]int{{segment.Start, stop}}, protected),
+			})
+		}
+		return ast.WalkSkipChildren, nil
+	})
+	return lines
+}
+
+// NonCodeRanges returns the parts of the source outside code blocks and code spans.
+func (d *Document) NonCodeRanges() [][2]int {
+	var code [][2]int
+	ast.Walk(d.root, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch n.Kind() {
+		case ast.KindCodeBlock, ast.KindFencedCodeBlock:
+			segments := n.Lines()
+			for i := 0; i < segments.Len(); i++ {
+				code = append(code, [2]int{segments.At(i).Start, segments.At(i).Stop})
+			}
+			return ast.WalkSkipChildren, nil
+		}
+		if n.Type() == ast.TypeBlock && holdsInlineContent(n) {
+			code = append(code, d.inlineCode(n)...)
+			return ast.WalkSkipChildren, nil
+		}
+		return ast.WalkContinue, nil
+	})
+	return subtract([][2]int{{0, len(d.source)}}, code)
+}
+
+// inlineCode returns the source ranges of code spans and raw HTML inside block.
+func (d *Document) inlineCode(block ast.Node) [][2]int {
+	var ranges [][2]int
+	ast.Walk(block, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch n := n.(type) {
+		case *ast.CodeSpan:
+			start, stop := -1, -1
+			for c := n.FirstChild(); c != nil; c = c.NextSibling() {
+				if t, ok := c.(*ast.Text); ok {
+					if start == -1 {
+						start = t.Segment.Start
+					}
+					stop = t.Segment.Stop
+				}
+			}
+			if start == -1 {
+				return ast.WalkSkipChildren, nil
+			}
+			// Widen to the backticks around the code
+			for start > 0 && d.source[start-1] == '`' {
+				start--
+			}
+			for stop < len(d.source) && d.source[stop] == '`' {
+				stop++
+			}
+			ranges = append(ranges, [2]int{start, stop})
+			return ast.WalkSkipChildren, nil
+		case *ast.RawHTML:
+			for i := 0; i < n.Segments.Len(); i++ {
+				ranges = append(ranges, [2]int{n.Segments.At(i).Start, n.Segments.At(i).Stop})
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+	return ranges
+}
+
+// subtract removes the holes from ranges.
+func subtract(ranges [][2]int, holes [][2]int) [][2]int {
+	for _, hole := range holes {
+		var result [][2]int
+		for _, r := range ranges {
+			if hole[1] <= r[0] || hole[0] >= r[1] {
+				result = append(result, r)
+				continue
+			}
+			if hole[0] > r[0] {
+				result = append(result, [2]int{r[0], hole[0]})
+			}
+			if hole[1] < r[1] {
+				result = append(result, [2]int{hole[1], r[1]})
+			}
+		}
+		ranges = result
+	}
+	return ranges
+}
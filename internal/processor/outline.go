@@ -0,0 +1,232 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"net/url"
+	"outline-hexo-connector/internal/config"
+	"regexp"
+	"strings"
+
+	"github.com/yuin/goldmark/ast"
+	east "github.com/yuin/goldmark/extension/ast"
+)
+
+// Targets of the constructs converted by the outline stage
+const (
+	TargetKeep = "keep"
+	TargetHTML = "html"
+	TargetHexo = "hexo"
+)
+
+var (
+	noticeStartRe = regexp.MustCompile(`^\\?:::\s*(info|warning|tip|success|notice)\s*$`)
+	noticeEndRe   = regexp.MustCompile(`^\\?:::\s*$`)
+	highlightRe   = regexp.MustCompile(`==([^=\s](?:[^=]*?[^=\s])?)==`)
+	// Outline writes underline as __text__, not to be confused with snake_case words
+	underlineRe = regexp.MustCompile(`(^|[^\w\\])__([^_\s](?:[^_]*?[^_\s])?)__`)
+	checkboxRe  = regexp.MustCompile(`^\[([ xX])\]`)
+
+	youtubeRe = regexp.MustCompile(`^https?://(?:www\.|m\.)?(?:youtube\.com/watch\?(?:.*&)?v=|youtu\.be/)([\w-]{11})`)
+	figmaRe   = regexp.MustCompile(`^https://(?:www\.)?figma\.com/(?:file|design|proto|board)/`)
+	gistRe    = regexp.MustCompile(`^https://gist\.github\.com/([\w-]+/)?([0-9a-f]+)/?$`)
+)
+
+// noticeClasses maps Outline notice types to Fluid note classes.
+var noticeClasses = map[string]string{
+	"info":    "info",
+	"warning": "warning",
+	"tip":     "success",
+	"success": "success",
+	"notice":  "primary",
+}
+
+// outlineStage converts Outline's own Markdown constructs, which Hexo would show as
+// literal text, into Hexo tags or HTML.
+type outlineStage struct {
+	Notice    string `yaml:"notice"`
+	Embed     string `yaml:"embed"`
+	Checklist string `yaml:"checklist"`
+	Highlight string `yaml:"highlight"`
+	Underline string `yaml:"underline"`
+}
+
+func newOutlineStage(cfg config.StageConfig) (Stage, error) {
+	stage := &outlineStage{
+		Embed:     TargetHTML,
+		Checklist: TargetHTML,
+		Highlight: TargetHTML,
+		Underline: TargetHTML,
+	}
+	if err := cfg.Decode(stage); err != nil {
+		return nil, err
+	}
+
+	for _, option := range []struct {
+		name    string
+		value   string
+		targets []string
+	}{
+		{"notice", stage.Notice, []string{TargetHexo, TargetHTML, TargetKeep}},
+		{"embed", stage.Embed, []string{TargetHTML, TargetKeep}},
+		{"checklist", stage.Checklist, []string{TargetHTML, TargetKeep}},
+		{"highlight", stage.Highlight, []string{TargetHTML, TargetKeep}},
+		{"underline", stage.Underline, []string{TargetHTML, TargetKeep}},
+	} {
+		// Without a notice target the one of the generator is used
+		valid := option.name == "notice" && option.value == ""
+		for _, target := range option.targets {
+			valid = valid || option.value == target
+		}
+		if !valid {
+			return nil, fmt.Errorf("Invalid %s target %q, expected one of %s", option.name, option.value, strings.Join(option.targets, ", "))
+		}
+	}
+	return stage, nil
+}
+
+func (s *outlineStage) Process(ctx context.Context, env *Env, doc *Document, result *MetadataAndText) error {
+	source := doc.Source()
+	notice := s.Notice
+	if notice == "" {
+		// {% note %} is a tag of Hexo's Fluid theme, other generators get the HTML it renders
+		notice = TargetHexo
+		if env.Generator != "" && env.Generator != config.GeneratorHexo {
+			notice = TargetHTML
+		}
+	}
+
+	// Block constructs first, inline marks are only converted on lines left alone
+	handled := make(map[int]bool)
+	if s.Embed == TargetHTML {
+		for _, line := range s.convertEmbeds(doc) {
+			handled[line] = true
+		}
+	}
+	if s.Checklist == TargetHTML {
+		s.convertChecklists(doc)
+	}
+
+	for _, line := range doc.InlineLines() {
+		if handled[line.Start] {
+			continue
+		}
+		text := source[line.Start:line.Stop]
+		startsWithText := len(line.Ranges) > 0 && line.Ranges[0][0] == line.Start
+
+		if notice != TargetKeep && startsWithText {
+			if match := noticeStartRe.FindSubmatch(text); match != nil {
+				doc.Replace(line.Start, line.Stop, noticeStart(notice, string(match[1])))
+				continue
+			}
+			if noticeEndRe.Match(text) {
+				doc.Replace(line.Start, line.Stop, noticeEnd(notice))
+				continue
+			}
+		}
+
+		for _, r := range line.Ranges {
+			part := string(source[r[0]:r[1]])
+			converted := part
+			if s.Highlight == TargetHTML {
+				converted = highlightRe.ReplaceAllString(converted, "<mark>$1</mark>")
+			}
+			if s.Underline == TargetHTML {
+				converted = underlineRe.ReplaceAllString(converted, "$1<u>$2</u>")
+			}
+			if converted != part {
+				doc.Replace(r[0], r[1], converted)
+			}
+		}
+	}
+	return nil
+}
+
+func noticeStart(target string, kind string) string {
+	if target == TargetHexo {
+		return "{% note " + noticeClasses[kind] + " %}"
+	}
+	return fmt.Sprintf("<div class=\"note note-%s\">\n", noticeClasses[kind])
+}
+
+func noticeEnd(target string) string {
+	if target == TargetHexo {
+		return "{% endnote %}"
+	}
+	return "\n</div>"
+}
+
+// convertEmbeds turns paragraphs holding nothing but a link to an embeddable page into
+// the embed. It returns the start of the converted lines.
+func (s *outlineStage) convertEmbeds(doc *Document) []int {
+	var converted []int
+	ast.Walk(doc.root, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering || n.Kind() != ast.KindParagraph {
+			return ast.WalkContinue, nil
+		}
+		if n.ChildCount() != 1 || n.Lines().Len() != 1 {
+			return ast.WalkSkipChildren, nil
+		}
+
+		var destination string
+		switch link := n.FirstChild().(type) {
+		case *ast.Link:
+			destination = string(link.Destination)
+		case *ast.AutoLink:
+			destination = string(link.URL(doc.source))
+		default:
+			return ast.WalkSkipChildren, nil
+		}
+
+		if embed := embedHTML(destination); embed != "" {
+			line := n.Lines().At(0)
+			stop := line.Stop
+			for stop > line.Start && (doc.source[stop-1] == '\n' || doc.source[stop-1] == '\r') {
+				stop--
+			}
+			doc.Replace(line.Start, stop, embed)
+			converted = append(converted, line.Start)
+		}
+		return ast.WalkSkipChildren, nil
+	})
+	return converted
+}
+
+func embedHTML(destination string) string {
+	if match := youtubeRe.FindStringSubmatch(destination); match != nil {
+		return fmt.Sprintf(`<iframe width="560" height="315" src="https://www.youtube-nocookie.com/embed/%s" frameborder="0" allowfullscreen></iframe>`, match[1])
+	}
+	if figmaRe.MatchString(destination) {
+		src := "https://www.figma.com/embed?embed_host=share&url=" + url.QueryEscape(destination)
+		return fmt.Sprintf(`<iframe width="800" height="450" src="%s" frameborder="0" allowfullscreen></iframe>`, html.EscapeString(src))
+	}
+	if match := gistRe.FindStringSubmatch(destination); match != nil {
+		return fmt.Sprintf(`<script src="https://gist.github.com/%s%s.js"></script>`, match[1], match[2])
+	}
+	return ""
+}
+
+// convertChecklists turns the [ ] and [x] of task list items into checkboxes.
+func (s *outlineStage) convertChecklists(doc *Document) {
+	ast.Walk(doc.root, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		checkBox, ok := n.(*east.TaskCheckBox)
+		if !entering || !ok {
+			return ast.WalkContinue, nil
+		}
+		block := checkBox.Parent()
+		if block == nil || block.Lines().Len() == 0 {
+			return ast.WalkContinue, nil
+		}
+
+		start := block.Lines().At(0).Start
+		if match := checkboxRe.FindIndex(doc.source[start:]); match != nil {
+			input := `<input type="checkbox" disabled>`
+			if checkBox.IsChecked {
+				input = `<input type="checkbox" checked disabled>`
+			}
+			doc.Replace(start+match[0], start+match[1], input)
+		}
+		return ast.WalkContinue, nil
+	})
+}
@@ -1,69 +1,167 @@
 package processor
 
 import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"outline-hexo-connector/internal/generator"
 	"regexp"
 	"strings"
 )
 
 type MetadataAndText struct {
-	BannerImg string
-	IndexImg  string
-	Tags      []string
-	Text      string
-	Archive   bool
+	BannerImg   string
+	IndexImg    string
+	Tags        []string
+	Text        string
+	Archive     bool
+	Math        bool
+	Mermaid     bool
+	FrontMatter *generator.FrontMatter
+	// Directives are the directives found in the document, for display
+	Directives []string
+	// Attachments maps the attachments found in the document to the URLs they point at now
+	Attachments map[string]string
+	// Warnings are problems with the document that did not stop processing
+	Warnings []string
 }
 
-func ExtractMetadataAndText(text string) *MetadataAndText {
-	metadataAndText := &MetadataAndText{
-		Text: text,
-	}
+// AddDirective notes a directive found in the document.
+func (m *MetadataAndText) AddDirective(format string, args ...any) {
+	m.Directives = append(m.Directives, fmt.Sprintf(format, args...))
+}
 
-	// We want picture which alt text is banner_img or index_img or banner_index_img
-	reBannerAndIndex := regexp.MustCompile(`!\[(?:banner_index_img|index_banner_img)\]\((.*?)\)`)
-	if match := reBannerAndIndex.FindStringSubmatch(text); len(match) > 1 {
-		metadataAndText.BannerImg = match[1]
-		metadataAndText.IndexImg = match[1]
-		metadataAndText.Text = reBannerAndIndex.ReplaceAllString(metadataAndText.Text, "[REMOVED]")
-	}
+// Warnf logs a problem with the document and keeps it in Warnings.
+func (m *MetadataAndText) Warnf(format string, args ...any) {
+	warning := fmt.Sprintf(format, args...)
+	log.Printf("Warning - %s", warning)
+	m.Warnings = append(m.Warnings, warning)
+}
+
+var (
+	moreRe    = regexp.MustCompile(`^\\?\+>\s*More:.*$`)
+	tagsRe    = regexp.MustCompile(`^\\?\+>\s*Tags:\s*(.*)$`)
+	archiveRe = regexp.MustCompile(`^\\?\+>\s*Archived\s*$`)
+)
+
+// ExtractMetadata takes the banner and index images and the Tags and Archived directive
+// lines out of the paragraphs of a document.
+func ExtractMetadata(ctx context.Context, env *Env, doc *Document, result *MetadataAndText) error {
+	var images images
+	source := doc.Source()
+	links := doc.Links()
+	for _, line := range doc.InlineLines() {
+		if len(line.Ranges) == 0 || line.Ranges[0][0] != line.Start {
+			// Starts with code
+			continue
+		}
+		text := source[line.Start:line.Stop]
 
-	reBanner := regexp.MustCompile(`!\[banner_img\]\((.*?)\)`)
-	if match := reBanner.FindStringSubmatch(text); len(match) > 1 {
-		metadataAndText.BannerImg = match[1]
-		metadataAndText.Text = reBanner.ReplaceAllString(metadataAndText.Text, "[REMOVED]")
+		if match := tagsRe.FindSubmatch(text); match != nil {
+			if result.Tags == nil {
+				rawTags := strings.FieldsFunc(string(match[1]), func(r rune) bool {
+					return r == ',' || r == '，'
+				})
+				for _, t := range rawTags {
+					result.Tags = append(result.Tags, strings.TrimSpace(t))
+				}
+				result.AddDirective("Tags: %s", strings.Join(result.Tags, ", "))
+			}
+			doc.RemoveLine(line)
+			continue
+		}
+		if archiveRe.Match(text) {
+			result.Archive = true
+			result.AddDirective("Archived")
+			doc.RemoveLine(line)
+			continue
+		}
+
+		images.extract(doc, line, links)
 	}
 
-	reIndex := regexp.MustCompile(`!\[index_img\]\((.*?)\)`)
-	if match := reIndex.FindStringSubmatch(text); len(match) > 1 {
-		metadataAndText.IndexImg = match[1]
-		metadataAndText.Text = reIndex.ReplaceAllString(metadataAndText.Text, "[REMOVED]")
+	result.BannerImg = firstNonEmpty(images.banner, images.bannerAndIndex)
+	result.IndexImg = firstNonEmpty(images.index, images.bannerAndIndex)
+	if result.BannerImg != "" {
+		result.AddDirective("Banner image: %s", result.BannerImg)
+	}
+	if result.IndexImg != "" {
+		result.AddDirective("Index image: %s", result.IndexImg)
 	}
+	return nil
+}
 
-	reMore := regexp.MustCompile(`(?m)^\\?\+>\s*More:.*$`)
-	metadataAndText.Text = reMore.ReplaceAllString(metadataAndText.Text, "<!-- more -->")
+// ReplaceMoreMarker turns More directive lines into the <!-- more --> excerpt marker.
+func ReplaceMoreMarker(ctx context.Context, env *Env, doc *Document, result *MetadataAndText) error {
+	source := doc.Source()
+	for _, line := range doc.InlineLines() {
+		if len(line.Ranges) > 0 && line.Ranges[0][0] == line.Start && moreRe.Match(source[line.Start:line.Stop]) {
+			doc.Replace(line.Start, line.Stop, "<!-- more -->")
+			result.AddDirective("More")
+		}
+	}
+	return nil
+}
 
-	// Extract tags from the line starting with "+> Tags: "
-	reTags := regexp.MustCompile(`(?m)^\\?\+>\s*Tags:\s*(.*)$`)
-	if match := reTags.FindStringSubmatch(text); len(match) > 1 {
-		tagStr := match[1]
+// images are the first banner and index images found in a document.
+type images struct {
+	bannerAndIndex string
+	banner         string
+	index          string
+}
 
-		rawTags := strings.FieldsFunc(tagStr, func(r rune) bool {
-			return r == ',' || r == '，'
-		})
-		for _, t := range rawTags {
-			metadataAndText.Tags = append(metadataAndText.Tags, strings.TrimSpace(t))
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
 		}
-
-		metadataAndText.Text = reTags.ReplaceAllString(metadataAndText.Text, "[REMOVED]")
 	}
+	return ""
+}
 
-	reArchive := regexp.MustCompile(`(?m)^\\?\+>\s*Archived\s*$`)
-	if reArchive.MatchString(metadataAndText.Text) {
-		metadataAndText.Archive = true
-		metadataAndText.Text = reArchive.ReplaceAllString(metadataAndText.Text, "[REMOVED]")
-	}
+// extract records the images of line with the alt text banner_img, index_img or
+// banner_index_img and removes them from doc.
+func (i *images) extract(doc *Document, line Line, links []Link) {
+	source := doc.Source()
 
-	// I hate regex. Why ReplaceAllString(Text, "") would always leaves an empty line?
-	// Or maybe I just suck at regex.
+	var removed [][2]int
+	for _, link := range links {
+		if !link.IsImage() || link.Start < line.Start || link.Stop > line.Stop {
+			continue
+		}
+		var image *string
+		switch doc.Text(link) {
+		case "banner_index_img", "index_banner_img":
+			image = &i.bannerAndIndex
+		case "banner_img":
+			image = &i.banner
+		case "index_img":
+			image = &i.index
+		default:
+			continue
+		}
+		if *image == "" {
+			*image = link.Destination()
+		}
+		removed = append(removed, [2]int{link.Start, link.Stop})
+	}
+	if len(removed) == 0 {
+		return
+	}
 
-	return metadataAndText
+	rest := subtract([][2]int{{line.Start, line.Stop}}, removed)
+	blank := true
+	for _, r := range rest {
+		if len(bytes.TrimSpace(source[r[0]:r[1]])) > 0 {
+			blank = false
+		}
+	}
+	if blank {
+		doc.RemoveLine(line)
+		return
+	}
+	for _, r := range removed {
+		doc.Replace(r[0], r[1], "")
+	}
 }
@@ -0,0 +1,53 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"outline-hexo-connector/internal/config"
+)
+
+// Pipeline runs the Markdown of documents through a list of stages in order.
+type Pipeline struct {
+	stages []Stage
+}
+
+// NewPipeline creates the stages listed in cfg, or DefaultStages when the list is empty.
+func NewPipeline(cfg []config.StageConfig) (*Pipeline, error) {
+	if len(cfg) == 0 {
+		for _, name := range DefaultStages {
+			cfg = append(cfg, config.StageConfig{Name: name})
+		}
+	}
+
+	pipeline := &Pipeline{}
+	for _, stageCfg := range cfg {
+		stagesMu.RLock()
+		factory, ok := stages[stageCfg.Name]
+		stagesMu.RUnlock()
+		if !ok {
+			return nil, fmt.Errorf("Unknown processor stage - %s", stageCfg.Name)
+		}
+
+		stage, err := factory(stageCfg)
+		if err != nil {
+			return nil, fmt.Errorf("Error creating processor stage %s - %w", stageCfg.Name, err)
+		}
+		pipeline.stages = append(pipeline.stages, stage)
+	}
+	return pipeline, nil
+}
+
+// Process parses text and runs the stages on it. Each stage sees the tree of the Markdown
+// as left by the previous one.
+func (p *Pipeline) Process(ctx context.Context, env *Env, text string) (*MetadataAndText, error) {
+	result := &MetadataAndText{}
+	doc := Parse(text)
+	for _, stage := range p.stages {
+		if err := stage.Process(ctx, env, doc, result); err != nil {
+			return nil, err
+		}
+		doc.Commit()
+	}
+	result.Text = doc.String()
+	return result, nil
+}
@@ -0,0 +1,312 @@
+package processor
+
+import (
+	"context"
+	"outline-hexo-connector/internal/config"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+const attachmentID = "0a1b2c3d-0000-4000-8000-0123456789ab"
+
+type fakeAttachments struct{}
+
+func (fakeAttachments) GetAttachmentUrl(ctx context.Context, attachmentID string) (string, error) {
+	return "/images/" + attachmentID + ".png", nil
+}
+
+type fakeDocuments struct{}
+
+func (fakeDocuments) ResolveDocumentLink(ctx context.Context, urlID string) (LinkedPost, bool, error) {
+	switch urlID {
+	case "AbCdEf1234":
+		return LinkedPost{Name: "post", Permalink: "/2024/post/"}, true, nil
+	case "NoPerm0000":
+		// Redirect_Permalink not set
+		return LinkedPost{Name: "draft"}, true, nil
+	}
+	return LinkedPost{}, false, nil
+}
+
+func process(t *testing.T, stages []config.StageConfig, text string) *MetadataAndText {
+	t.Helper()
+	pipeline, err := NewPipeline(stages)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env := &Env{DocumentID: "doc", Attachments: fakeAttachments{}, Documents: fakeDocuments{}}
+	result, err := pipeline.Process(context.Background(), env, text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func TestAttachments(t *testing.T) {
+	const (
+		from = "/api/attachments.redirect?id=" + attachmentID
+		to   = "/images/" + attachmentID + ".png"
+	)
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"image", "![a](" + from + ")\n", "![a](" + to + ")\n"},
+		{"size hint", "![a](" + from + ` \"=100x50\")` + "\n", "![a](" + to + ")\n"},
+		{"file size", "[file.pdf 12345](" + from + ")\n", "[file.pdf](" + to + ")\n"},
+		{"bracket in text", `[a \] b](` + from + ")\n", `[a \] b](` + to + ")\n"},
+		{"nested brackets", "[a [b] c](" + from + ")\n", "[a [b] c](" + to + ")\n"},
+		{"title", "![a](" + from + ` "t")` + "\n", "![a](" + to + ")\n"},
+		{"reference", "![a][img]\n\n[img]: " + from + "\n", "![a](" + to + ")\n\n[img]: " + from + "\n"},
+		{"image in link", "[![a](" + from + ")](" + from + ")\n", "[![a](" + to + ")](" + to + ")\n"},
+		{"code span", "`![a](" + from + ")`\n", "`![a](" + from + ")`\n"},
+		{"code block", "```\n![a](" + from + ")\n```\n", "```\n![a](" + from + ")\n```\n"},
+		{"other link", "[a](https://example.com/api/x)\n", "[a](https://example.com/api/x)\n"},
+	}
+	for _, tt := range tests {
+		result := process(t, []config.StageConfig{{Name: "attachments"}}, tt.text)
+		if result.Text != tt.want {
+			t.Errorf("%s\n got %q\nwant %q", tt.name, result.Text, tt.want)
+		}
+	}
+}
+
+func TestMetadataImages(t *testing.T) {
+	tests := []struct {
+		name   string
+		text   string
+		want   string
+		banner string
+		index  string
+	}{
+		{"both", "![banner_index_img](/b.png)\n\nText\n", "Text\n", "/b.png", "/b.png"},
+		{"separate", "Text ![banner_img](/b.png \"title\") and ![index_img](</i (1).png>)\n", "Text  and \n", "/b.png", "/i (1).png"},
+		{"reference", "![index_img][i]\n\n[i]: /i.png\n", "[i]: /i.png\n", "", "/i.png"},
+		{"first wins", "![banner_img](/1.png)\n![banner_img](/2.png)\n", "", "/1.png", ""},
+		{"other alt", "![banner](/b.png)\n", "![banner](/b.png)\n", "", ""},
+		{"code", "```\n![banner_img](/b.png)\n```\n", "```\n![banner_img](/b.png)\n```\n", "", ""},
+	}
+	for _, tt := range tests {
+		result := process(t, []config.StageConfig{{Name: "metadata"}}, tt.text)
+		if result.Text != tt.want || result.BannerImg != tt.banner || result.IndexImg != tt.index {
+			t.Errorf("%s\n got %q, banner %q, index %q\nwant %q, banner %q, index %q", tt.name, result.Text, result.BannerImg, result.IndexImg, tt.want, tt.banner, tt.index)
+		}
+	}
+}
+
+func TestDocLinks(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"link", "[Post](/doc/post-AbCdEf1234#part)\n", "[Post](/2024/post/#part)\n"},
+		{"bracket in text", `See [a \] b](/doc/post-AbCdEf1234)` + "\n", `See [a \] b](/2024/post/)` + "\n"},
+		{"reference", "[Post][p]\n\n[p]: /doc/post-AbCdEf1234\n", "[Post](/2024/post/)\n\n[p]: /doc/post-AbCdEf1234\n"},
+		{"unpublished", "[Other](/doc/other-XyXyXy0000)\n", "Other\n"},
+		{"no permalink", "[Say \"hi\"](/doc/draft-NoPerm0000)\n", "{% post_link draft \"Say &quot;hi&quot;\" %}\n"},
+		{"image", "![a](/doc/post-AbCdEf1234)\n", "![a](/doc/post-AbCdEf1234)\n"},
+		{"code span", "`[Post](/doc/post-AbCdEf1234)`\n", "`[Post](/doc/post-AbCdEf1234)`\n"},
+	}
+	for _, tt := range tests {
+		result := process(t, []config.StageConfig{{Name: "doclinks"}}, tt.text)
+		if result.Text != tt.want {
+			t.Errorf("%s\n got %q\nwant %q", tt.name, result.Text, tt.want)
+		}
+	}
+}
+
+func TestLinkRewrite(t *testing.T) {
+	var stages []config.StageConfig
+	if err := yaml.Unmarshal([]byte("- {name: links, rewrite: {/files/: /static/, /files/img/: /img/}}"), &stages); err != nil {
+		t.Fatal(err)
+	}
+
+	text := "[a [b]](/files/a.pdf) ![c]( </files/img/c.png>) [d](/other/) `[e](/files/e)` [f][f]\n\n[f]: </files/f g.pdf>\n"
+	want := "[a [b]](/static/a.pdf) ![c]( </img/c.png>) [d](/other/) `[e](/files/e)` [f](</static/f g.pdf>)\n\n[f]: </files/f g.pdf>\n"
+	if got := process(t, stages, text).Text; got != want {
+		t.Errorf("\n got %q\nwant %q", got, want)
+	}
+}
+
+func TestFrontMatterFirstBlock(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		want    string
+		excerpt any
+	}{
+		{"code block", "```frontmatter\nexcerpt: Hi\n```\n\nText\n", "Text\n", "Hi"},
+		{"meta", "+> Meta:\nexcerpt: Hi\n\nText\n", "Text\n", "Hi"},
+		{"leading blank lines", "\n\n```frontmatter\nexcerpt: Hi\n```\nText\n", "\n\nText\n", "Hi"},
+		{"after text", "Text\n\n```frontmatter\nexcerpt: Hi\n```\n", "Text\n\n```frontmatter\nexcerpt: Hi\n```\n", nil},
+		{"meta after text", "Text\n\n+> Meta:\nexcerpt: Hi\n", "Text\n\n+> Meta:\nexcerpt: Hi\n", nil},
+		{"after heading", "# Title\n\n```frontmatter\nexcerpt: Hi\n```\n", "# Title\n\n```frontmatter\nexcerpt: Hi\n```\n", nil},
+		// As Outline writes it
+		{"meta field paragraphs", "+> Meta:\n\nexcerpt: Hi\n\nsticky: 1\n\nText\n", "Text\n", "Hi"},
+		{"meta escaped", "\\+> Meta:\n\nexcerpt: Hi\\!\n\nText\n", "Text\n", "Hi!"},
+		{"meta hard breaks", "+> Meta:\\\nexcerpt: Hi\\\nsticky: 1\n\nText\n", "Text\n", "Hi"},
+		{"meta literal breaks", "+> Meta:\\nexcerpt: Hi\\nsticky: 1\n\nText\n", "Text\n", "Hi"},
+		{"meta fields end at text", "+> Meta:\n\nexcerpt: Hi\n\nSome text\n", "Some text\n", "Hi"},
+		{"meta without fields", "+> Meta:\n\nText\n", "+> Meta:\n\nText\n", nil},
+		{"empty code block", "```frontmatter\n```\n\nText\n", "```frontmatter\n```\n\nText\n", nil},
+	}
+	for _, tt := range tests {
+		result := process(t, []config.StageConfig{{Name: "frontmatter"}}, tt.text)
+		var excerpt any
+		if result.FrontMatter != nil {
+			excerpt, _ = result.FrontMatter.Get("excerpt")
+		}
+		if result.Text != tt.want || excerpt != tt.excerpt {
+			t.Errorf("%s\n got %q, excerpt %v\nwant %q, excerpt %v", tt.name, result.Text, excerpt, tt.want, tt.excerpt)
+		}
+	}
+}
+
+func TestFrontMatterOutlineFields(t *testing.T) {
+	text := "+> Meta:\\\nexcerpt: Hi\\\ncomments: false\n\nsticky: 100\n\nlang: en\n\nText\n"
+	result := process(t, []config.StageConfig{{Name: "frontmatter"}}, text)
+	if result.Text != "Text\n" {
+		t.Errorf("\n got %q\nwant %q", result.Text, "Text\n")
+	}
+	want := map[string]any{"excerpt": "Hi", "comments": false, "sticky": 100, "lang": "en"}
+	for key, value := range want {
+		if got, _ := result.FrontMatter.Get(key); got != value {
+			t.Errorf("%s = %#v, want %#v", key, got, value)
+		}
+	}
+
+	result = process(t, []config.StageConfig{{Name: "frontmatter"}}, "+> Meta:\n\nText\n")
+	if len(result.Warnings) != 1 {
+		t.Errorf("Warnings = %q, want one for the block without fields", result.Warnings)
+	}
+}
+
+func TestNoticeTargetOfGenerator(t *testing.T) {
+	pipeline, err := NewPipeline([]config.StageConfig{{Name: "outline"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		generator string
+		want      string
+	}{
+		{"", "{% note info %}\nText\n{% endnote %}\n"},
+		{config.GeneratorHexo, "{% note info %}\nText\n{% endnote %}\n"},
+		{config.GeneratorHugo, "<div class=\"note note-info\">\n\nText\n\n</div>\n"},
+	}
+	for _, tt := range tests {
+		result, err := pipeline.Process(context.Background(), &Env{DocumentID: "doc", Generator: tt.generator}, ":::info\nText\n:::\n")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Text != tt.want {
+			t.Errorf("%q\n got %q\nwant %q", tt.generator, result.Text, tt.want)
+		}
+	}
+}
+
+func TestDetectMath(t *testing.T) {
+	tests := []struct {
+		text string
+		want bool
+	}{
+		{"Euler: $e^{i\\pi} + 1 = 0$.\n", true},
+		{"$x$\n", true},
+		{"公式$x^2$表示平方\n", true},
+		{"$$\nx = 1\n$$\n", true},
+		{"Inline $$x$$ block\n", true},
+		{"It costs $5 and $10.\n", false},
+		{"Price: $5.\n", false},
+		{"Set $HOME/$USER first\n", false},
+		{"PATH=$HOME/bin:$PATH\n", false},
+		{"echo $HOME $PATH\n", false},
+		{"Escaped \\$x\\$ dollars\n", false},
+		{"Code `$x$` span\n", false},
+		{"```sh\necho $x$\n```\n", false},
+		{"```\n$$\nx\n$$\n```\n", false},
+		{"    indented $x$ code\n", false},
+	}
+	for _, tt := range tests {
+		if got := process(t, []config.StageConfig{{Name: "math"}}, tt.text).Math; got != tt.want {
+			t.Errorf("%q - math %v, want %v", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestDetectMermaid(t *testing.T) {
+	tests := []struct {
+		text string
+		want bool
+	}{
+		{"```mermaid\ngraph TD\n  A --> B\n```\n", true},
+		{"> ```mermaid\n> graph TD\n> ```\n", true},
+		{"```go\nfmt.Println(\"mermaid\")\n```\n", false},
+		{"A `mermaid` word\n", false},
+	}
+	for _, tt := range tests {
+		if got := process(t, []config.StageConfig{{Name: "mermaid"}}, tt.text).Mermaid; got != tt.want {
+			t.Errorf("%q - mermaid %v, want %v", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestOutlineStage(t *testing.T) {
+	const (
+		youtube = "[https://youtu.be/dQw4w9WgXcQ](https://youtu.be/dQw4w9WgXcQ)\n"
+		gist    = "[https://gist.github.com/someone/0123abcd](https://gist.github.com/someone/0123abcd)\n"
+		figma   = "[Design](https://www.figma.com/file/AbC/Design)\n"
+	)
+	tests := []struct {
+		name   string
+		option string
+		text   string
+		want   string
+	}{
+		{"notice hexo", "notice: hexo", ":::warning\nCareful\n:::\n", "{% note warning %}\nCareful\n{% endnote %}\n"},
+		{"notice hexo tip", "notice: hexo", ":::tip\nTry\n:::\n", "{% note success %}\nTry\n{% endnote %}\n"},
+		{"notice hexo escaped", "notice: hexo", "\\:::info\nNote\n\\:::\n", "{% note info %}\nNote\n{% endnote %}\n"},
+		{"notice html", "notice: html", ":::warning\nCareful\n:::\n", "<div class=\"note note-warning\">\n\nCareful\n\n</div>\n"},
+		{"notice keep", "notice: keep", ":::warning\nCareful\n:::\n", ":::warning\nCareful\n:::\n"},
+		{"notice in code", "notice: hexo", "```\n:::info\n:::\n```\n", "```\n:::info\n:::\n```\n"},
+
+		{"embed youtube", "embed: html", youtube, `<iframe width="560" height="315" src="https://www.youtube-nocookie.com/embed/dQw4w9WgXcQ" frameborder="0" allowfullscreen></iframe>` + "\n"},
+		{"embed gist", "embed: html", gist, `<script src="https://gist.github.com/someone/0123abcd.js"></script>` + "\n"},
+		{"embed figma", "embed: html", figma, `<iframe width="800" height="450" src="https://www.figma.com/embed?embed_host=share&amp;url=https%3A%2F%2Fwww.figma.com%2Ffile%2FAbC%2FDesign" frameborder="0" allowfullscreen></iframe>` + "\n"},
+		{"embed in text", "embed: html", "Watch " + youtube, "Watch " + youtube},
+		{"embed other link", "embed: html", "[Home](https://example.com/)\n", "[Home](https://example.com/)\n"},
+		{"embed keep", "embed: keep", youtube, youtube},
+
+		{"checklist html", "checklist: html", "- [ ] todo\n- [x] done\n", "- <input type=\"checkbox\" disabled> todo\n- <input type=\"checkbox\" checked disabled> done\n"},
+		{"checklist keep", "checklist: keep", "- [ ] todo\n- [x] done\n", "- [ ] todo\n- [x] done\n"},
+		{"checklist plain list", "checklist: html", "- [link](/a)\n", "- [link](/a)\n"},
+
+		{"highlight html", "highlight: html", "Some ==marked== text\n", "Some <mark>marked</mark> text\n"},
+		{"highlight in code", "highlight: html", "Some `==marked==` text\n", "Some `==marked==` text\n"},
+		{"highlight keep", "highlight: keep", "Some ==marked== text\n", "Some ==marked== text\n"},
+
+		{"underline html", "underline: html", "Some __under__ text\n", "Some <u>under</u> text\n"},
+		{"underline snake case", "underline: html", "A snake__case__word\n", "A snake__case__word\n"},
+		{"underline in code", "underline: html", "Call `__init__` first\n", "Call `__init__` first\n"},
+		{"underline keep", "underline: keep", "Some __under__ text\n", "Some __under__ text\n"},
+	}
+	for _, tt := range tests {
+		var stages []config.StageConfig
+		if err := yaml.Unmarshal([]byte("- {name: outline, "+tt.option+"}"), &stages); err != nil {
+			t.Fatal(err)
+		}
+		if got := process(t, stages, tt.text).Text; got != tt.want {
+			t.Errorf("%s\n got %q\nwant %q", tt.name, got, tt.want)
+		}
+	}
+
+	var stages []config.StageConfig
+	if err := yaml.Unmarshal([]byte("- {name: outline, embed: hexo}"), &stages); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewPipeline(stages); err == nil {
+		t.Error("NewPipeline accepted embed target hexo")
+	}
+}
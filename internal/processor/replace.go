@@ -0,0 +1,44 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"outline-hexo-connector/internal/config"
+	"regexp"
+	"strings"
+)
+
+// replaceStage replaces a regular expression everywhere outside code.
+type replaceStage struct {
+	re          *regexp.Regexp
+	replacement string
+}
+
+func newReplaceStage(cfg config.StageConfig) (Stage, error) {
+	var options struct {
+		Pattern     string `yaml:"pattern"`
+		Replacement string `yaml:"replacement"`
+	}
+	if err := cfg.Decode(&options); err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(options.Pattern) == "" {
+		return nil, fmt.Errorf("No pattern configured")
+	}
+	re, err := regexp.Compile(options.Pattern)
+	if err != nil {
+		return nil, err
+	}
+	return &replaceStage{re: re, replacement: options.Replacement}, nil
+}
+
+func (s *replaceStage) Process(ctx context.Context, env *Env, doc *Document, result *MetadataAndText) error {
+	source := doc.Source()
+	for _, r := range doc.NonCodeRanges() {
+		text := string(source[r[0]:r[1]])
+		if replaced := s.re.ReplaceAllString(text, s.replacement); replaced != text {
+			doc.Replace(r[0], r[1], replaced)
+		}
+	}
+	return nil
+}
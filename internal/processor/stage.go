@@ -0,0 +1,89 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"outline-hexo-connector/internal/config"
+	"sort"
+	"sync"
+)
+
+// Env is what stages get to know about the document being processed.
+type Env struct {
+	DocumentID string
+	// OutlineURL is the base URL of Outline, links below it are links to documents
+	OutlineURL string
+	// Attachments resolves attachment IDs to URLs, nil when attachments are left alone
+	Attachments AttachmentUrlProvider
+	// Documents resolves links to other documents, nil when they are left alone
+	Documents DocumentLinkResolver
+	// Generator is the site generator the post is written for, hexo when empty
+	Generator string
+}
+
+// Stage is one step of processing the Markdown of a document. It records edits on doc
+// and fills in what it finds into result.
+type Stage interface {
+	Process(ctx context.Context, env *Env, doc *Document, result *MetadataAndText) error
+}
+
+// StageFunc adapts a function to a Stage.
+type StageFunc func(ctx context.Context, env *Env, doc *Document, result *MetadataAndText) error
+
+func (f StageFunc) Process(ctx context.Context, env *Env, doc *Document, result *MetadataAndText) error {
+	return f(ctx, env, doc, result)
+}
+
+// StageFactory creates a stage from its entry in Processor_Stages.
+type StageFactory func(cfg config.StageConfig) (Stage, error)
+
+var (
+	stagesMu sync.RWMutex
+	stages   = make(map[string]StageFactory)
+)
+
+// DefaultStages are run when Processor_Stages is not set.
+var DefaultStages = []string{"frontmatter", "attachments", "doclinks", "metadata", "more", "outline", "math", "mermaid", "unescape"}
+
+// RegisterStage makes a stage available to Processor_Stages under name. It panics when
+// name is already registered.
+func RegisterStage(name string, factory StageFactory) {
+	stagesMu.Lock()
+	defer stagesMu.Unlock()
+	if _, ok := stages[name]; ok {
+		panic(fmt.Sprintf("processor: stage %s registered twice", name))
+	}
+	stages[name] = factory
+}
+
+// StageNames returns the names of all registered stages.
+func StageNames() []string {
+	stagesMu.RLock()
+	defer stagesMu.RUnlock()
+	names := make([]string, 0, len(stages))
+	for name := range stages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func simpleStage(stage StageFunc) StageFactory {
+	return func(cfg config.StageConfig) (Stage, error) {
+		return stage, nil
+	}
+}
+
+func init() {
+	RegisterStage("frontmatter", newFrontMatterStage)
+	RegisterStage("attachments", simpleStage(ConvertAttachments))
+	RegisterStage("doclinks", newDocLinkStage)
+	RegisterStage("metadata", simpleStage(ExtractMetadata))
+	RegisterStage("more", simpleStage(ReplaceMoreMarker))
+	RegisterStage("outline", newOutlineStage)
+	RegisterStage("math", simpleStage(DetectMath))
+	RegisterStage("mermaid", simpleStage(DetectMermaid))
+	RegisterStage("links", newLinkStage)
+	RegisterStage("replace", newReplaceStage)
+	RegisterStage("unescape", simpleStage(UnescapeNewlines))
+}
@@ -0,0 +1,347 @@
+package queue
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"hash/fnv"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Queue is a durable FIFO backed by an append-only JSON log file.
+// Entries are fsynced before Enqueue returns and are only forgotten once a worker
+// has finished them, so unfinished entries are replayed after a restart. Entries
+// that keep failing are moved to a dead letter file.
+type Queue struct {
+	mu       sync.Mutex
+	sendMu   sync.Mutex // Held from logging an entry until it is handed to a worker
+	path     string
+	deadPath string
+	file     *os.File
+	nextID   uint64
+	pending  int
+	backlog  []Entry
+	workers  []chan Entry
+	ctx      context.Context
+	running  sync.WaitGroup
+	backoff  time.Duration
+	finished func(Entry) // Called once an entry is done or dead, for tests
+}
+
+type Entry struct {
+	ID   uint64          `json:"id"`
+	Key  string          `json:"key,omitempty"`
+	Data json.RawMessage `json:"data,omitempty"`
+}
+
+type record struct {
+	Op string `json:"op"`
+	Entry
+}
+
+// deadRecord is an entry given up on, kept in the dead letter file.
+type deadRecord struct {
+	Entry
+	Error string `json:"error"`
+	Time  string `json:"time"`
+}
+
+const (
+	opAdd  = "add"
+	opDone = "done"
+)
+
+// An entry is given up on after about five minutes of failures
+const (
+	maxAttempts     = 10
+	retryBackoff    = 2 * time.Second
+	maxRetryBackoff = time.Minute
+)
+
+func Open(dir string) (*Queue, error) {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	q := &Queue{
+		path:     filepath.Join(dir, "queue.log"),
+		deadPath: filepath.Join(dir, "queue.dead.log"),
+		nextID:   1,
+		backoff:  retryBackoff,
+	}
+	err = q.load()
+	if err != nil {
+		return nil, err
+	}
+	err = q.compact()
+	if err != nil {
+		return nil, err
+	}
+
+	q.file, err = os.OpenFile(q.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if len(q.backlog) > 0 {
+		log.Printf("Queue has %d unfinished entries - Will replay them", len(q.backlog))
+	}
+	return q, nil
+}
+
+func (q *Queue) load() error {
+	file, err := os.Open(q.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var entries []Entry
+	done := make(map[uint64]bool)
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			var r record
+			if jsonErr := json.Unmarshal(line, &r); jsonErr != nil {
+				// Most likely a torn write from a crash, the entry was never acknowledged
+				log.Printf("Skipping corrupted queue record - %v", jsonErr)
+			} else {
+				switch r.Op {
+				case opAdd:
+					entries = append(entries, r.Entry)
+				case opDone:
+					done[r.ID] = true
+				}
+				if r.ID >= q.nextID {
+					q.nextID = r.ID + 1
+				}
+			}
+		}
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+	}
+
+	for _, entry := range entries {
+		if !done[entry.ID] {
+			q.backlog = append(q.backlog, entry)
+		}
+	}
+	q.pending = len(q.backlog)
+	return nil
+}
+
+// compact rewrites the log so it only holds the unfinished entries.
+func (q *Queue) compact() error {
+	tmpPath := q.path + ".tmp"
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+
+	writer := bufio.NewWriter(file)
+	for _, entry := range q.backlog {
+		err = writeRecord(writer, record{Op: opAdd, Entry: entry})
+		if err != nil {
+			file.Close()
+			return err
+		}
+	}
+	if err = writer.Flush(); err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, q.path)
+}
+
+func writeRecord(w io.Writer, r record) error {
+	line, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(line, '\n'))
+	return err
+}
+
+// Enqueue durably stores data and hands it to a worker. Entries with the same key
+// are always processed by the same worker, in the order they were enqueued.
+func (q *Queue) Enqueue(key string, data []byte) error {
+	// Keeps the order of the log until the entry reached its worker
+	q.sendMu.Lock()
+	defer q.sendMu.Unlock()
+
+	q.mu.Lock()
+	entry := Entry{ID: q.nextID, Key: key, Data: data}
+	err := writeRecord(q.file, record{Op: opAdd, Entry: entry})
+	if err == nil {
+		err = q.file.Sync()
+	}
+	if err != nil {
+		q.mu.Unlock()
+		return err
+	}
+	q.nextID++
+	q.pending++
+
+	if q.workers == nil {
+		q.backlog = append(q.backlog, entry)
+		q.mu.Unlock()
+		return nil
+	}
+	q.mu.Unlock()
+
+	q.dispatch(entry)
+	return nil
+}
+
+func (q *Queue) dispatch(entry Entry) {
+	h := fnv.New32a()
+	h.Write([]byte(entry.Key))
+	ch := q.workers[h.Sum32()%uint32(len(q.workers))]
+
+	select {
+	case ch <- entry:
+	case <-q.ctx.Done():
+		// Left unfinished in the log, will be replayed on next start
+	}
+}
+
+func (q *Queue) markDone(id uint64) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.pending--
+	if q.pending == 0 {
+		// Nothing left to replay, start over with an empty log
+		err := q.file.Truncate(0)
+		if err == nil {
+			return
+		}
+		log.Printf("Error truncating queue log - %v", err)
+	}
+
+	err := writeRecord(q.file, record{Op: opDone, Entry: Entry{ID: id}})
+	if err != nil {
+		log.Printf("Error marking queue entry %d as done - %v", id, err)
+	}
+}
+
+// Start launches the workers and replays unfinished entries. Start must be called
+// once, before anything that enqueues is exposed.
+func (q *Queue) Start(ctx context.Context, workers int, handler func(Entry) error) {
+	if workers <= 0 {
+		workers = 1
+	}
+
+	chans := make([]chan Entry, workers)
+	for i := range chans {
+		chans[i] = make(chan Entry, 256)
+		q.running.Add(1)
+		go q.work(ctx, chans[i], handler)
+	}
+
+	// The backlog goes first, entries enqueued meanwhile wait for it
+	q.sendMu.Lock()
+	defer q.sendMu.Unlock()
+
+	q.mu.Lock()
+	q.ctx = ctx
+	q.workers = chans
+	backlog := q.backlog
+	q.backlog = nil
+	q.mu.Unlock()
+
+	for _, entry := range backlog {
+		q.dispatch(entry)
+	}
+}
+
+func (q *Queue) work(ctx context.Context, ch <-chan Entry, handler func(Entry) error) {
+	defer q.running.Done()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case entry := <-ch:
+			err := q.process(ctx, entry, handler)
+			if ctx.Err() != nil {
+				// Possibly cut short, replayed on the next start
+				continue
+			}
+			if err != nil {
+				log.Printf("Error processing queue entry %d - %v - Giving up after %d attempts", entry.ID, err, maxAttempts)
+				if deadErr := q.bury(entry, err); deadErr != nil {
+					log.Printf("Error moving queue entry %d to %s - %v - Dropping it", entry.ID, q.deadPath, deadErr)
+				}
+			}
+			q.markDone(entry.ID)
+			if q.finished != nil {
+				q.finished(entry)
+			}
+		}
+	}
+}
+
+// process runs handler on entry, retrying failures with backoff, and returns the error
+// of the last attempt.
+func (q *Queue) process(ctx context.Context, entry Entry, handler func(Entry) error) error {
+	backoff := q.backoff
+	for attempt := 1; ; attempt++ {
+		err := handler(entry)
+		if err == nil || ctx.Err() != nil || attempt == maxAttempts {
+			return err
+		}
+
+		log.Printf("Error processing queue entry %d, retrying in %v - %v", entry.ID, backoff, err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, maxRetryBackoff)
+	}
+}
+
+// bury appends an entry that kept failing to the dead letter file.
+func (q *Queue) bury(entry Entry, cause error) error {
+	file, err := os.OpenFile(q.deadPath, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	line, err := json.Marshal(deadRecord{Entry: entry, Error: cause.Error(), Time: time.Now().Format(time.RFC3339)})
+	if err == nil {
+		_, err = file.Write(append(line, '\n'))
+	}
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// Close waits for the workers to stop, after the context given to Start is cancelled,
+// and closes the log.
+func (q *Queue) Close() error {
+	q.running.Wait()
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.file.Close()
+}
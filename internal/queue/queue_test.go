@@ -0,0 +1,211 @@
+package queue
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+// waitFor fails the test when ch is not closed in time.
+func waitFor(t *testing.T, ch <-chan struct{}, what string) {
+	t.Helper()
+	select {
+	case <-ch:
+	case <-time.After(10 * time.Second):
+		t.Fatal(what)
+	}
+}
+
+func TestRetryUntilDone(t *testing.T) {
+	dir := t.TempDir()
+	q, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q.backoff = time.Millisecond
+	finished := make(chan struct{})
+	q.finished = func(Entry) { close(finished) }
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	attempts := 0
+	q.Start(ctx, 1, func(entry Entry) error {
+		attempts++
+		if attempts == 1 {
+			return errors.New("outline unavailable")
+		}
+		return nil
+	})
+	if err := q.Enqueue("doc", []byte(`{}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	waitFor(t, finished, "entry was not retried")
+	cancel()
+	q.Close()
+	if attempts != 2 {
+		t.Errorf("attempts = %d, want 2", attempts)
+	}
+
+	q, err = Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+	if len(q.backlog) != 0 {
+		t.Errorf("backlog = %d entries, want 0", len(q.backlog))
+	}
+	if _, err := os.Stat(q.deadPath); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("dead letter file exists, want none - %v", err)
+	}
+}
+
+func TestCancelledEntryIsReplayed(t *testing.T) {
+	dir := t.TempDir()
+	q, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	started := make(chan struct{})
+	q.Start(ctx, 1, func(entry Entry) error {
+		close(started)
+		<-ctx.Done()
+		return nil
+	})
+	if err := q.Enqueue("doc", []byte(`{"id":1}`)); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, started, "entry was not started")
+	cancel()
+	// Waits for the worker to stop
+	q.Close()
+
+	q, err = Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+	if len(q.backlog) != 1 || string(q.backlog[0].Data) != `{"id":1}` {
+		t.Errorf("backlog = %+v, want the cancelled entry", q.backlog)
+	}
+}
+
+func TestExhaustedEntryIsDeadLettered(t *testing.T) {
+	dir := t.TempDir()
+	q, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q.backoff = time.Millisecond
+	finished := make(chan struct{})
+	q.finished = func(Entry) { close(finished) }
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	attempts := 0
+	q.Start(ctx, 1, func(entry Entry) error {
+		attempts++
+		return errors.New("document is broken")
+	})
+	if err := q.Enqueue("doc", []byte(`{"id":1}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	waitFor(t, finished, "entry was not given up on")
+	cancel()
+	q.Close()
+	if attempts != maxAttempts {
+		t.Errorf("attempts = %d, want %d", attempts, maxAttempts)
+	}
+
+	q, err = Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+	if len(q.backlog) != 0 {
+		t.Errorf("backlog = %+v, want the dead entry gone", q.backlog)
+	}
+
+	file, err := os.Open(q.deadPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	var dead []deadRecord
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var r deadRecord
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			t.Fatal(err)
+		}
+		dead = append(dead, r)
+	}
+	if len(dead) != 1 || string(dead[0].Data) != `{"id":1}` || dead[0].Error != "document is broken" {
+		t.Errorf("dead letters = %+v, want the failed entry", dead)
+	}
+}
+
+func TestSameKeyKeepsOrder(t *testing.T) {
+	dir := t.TempDir()
+	q, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Left from the last run, replayed before anything new
+	for i := 0; i < 10; i++ {
+		if err := q.Enqueue("doc", []byte(`{}`)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	const total = 200
+	var mu sync.Mutex
+	var order []uint64
+	allDone := make(chan struct{})
+	q.finished = func(Entry) {
+		mu.Lock()
+		defer mu.Unlock()
+		if len(order) == total {
+			close(allDone)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < (total-10)/10; j++ {
+				if err := q.Enqueue("doc", []byte(`{}`)); err != nil {
+					t.Error(err)
+				}
+			}
+		}()
+	}
+	q.Start(ctx, 4, func(entry Entry) error {
+		mu.Lock()
+		defer mu.Unlock()
+		order = append(order, entry.ID)
+		return nil
+	})
+	wg.Wait()
+
+	waitFor(t, allDone, "entries were not processed")
+	cancel()
+	q.Close()
+	for i := 1; i < len(order); i++ {
+		if order[i] < order[i-1] {
+			t.Fatalf("entry %d processed after entry %d", order[i], order[i-1])
+		}
+	}
+}
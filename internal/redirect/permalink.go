@@ -0,0 +1,77 @@
+package redirect
+
+import (
+	"fmt"
+	"outline-hexo-connector/internal/generator"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Characters hexo-util's slugize replaces with a dash
+var hexoSpecialRe = regexp.MustCompile(`[\s~` + "`" + `!@#$%^&*()\-_+=[\]{}|\\;:"'<>,.?/]+`)
+
+// hexoSlugize slugifies a category name like hexo-util's slugize with the default
+// filename_case: diacritics are dropped and special characters become dashes, case
+// and other letters are kept.
+func hexoSlugize(s string) string {
+	var b strings.Builder
+	for _, r := range norm.NFD.String(s) {
+		if unicode.Is(unicode.Mn, r) || unicode.IsControl(r) {
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return strings.Trim(hexoSpecialRe.ReplaceAllString(b.String(), "-"), "-")
+}
+
+// categorySlug returns the slug Hexo gives the innermost of categories, the slugs of
+// its parents and its own joined by slashes.
+func categorySlug(categories []string) string {
+	var slugs []string
+	for _, category := range categories {
+		if slug := hexoSlugize(category); slug != "" {
+			slugs = append(slugs, slug)
+		}
+	}
+	if len(slugs) == 0 {
+		return "uncategorized"
+	}
+	return strings.Join(slugs, "/")
+}
+
+// Permalink expands a Hexo style permalink pattern such as ":year/:month/:day/:title/"
+// for post, relative to the site root.
+func Permalink(pattern string, post *generator.Post) string {
+	date, _ := time.ParseInLocation(generator.TimeLayout, post.Date, time.Local)
+
+	replacer := strings.NewReplacer(
+		":year", fmt.Sprintf("%04d", date.Year()),
+		":month", fmt.Sprintf("%02d", date.Month()),
+		":i_month", fmt.Sprintf("%d", date.Month()),
+		":day", fmt.Sprintf("%02d", date.Day()),
+		":i_day", fmt.Sprintf("%d", date.Day()),
+		":hour", fmt.Sprintf("%02d", date.Hour()),
+		":minute", fmt.Sprintf("%02d", date.Minute()),
+		":second", fmt.Sprintf("%02d", date.Second()),
+		":post_title", post.Name,
+		":title", post.Name,
+		":name", post.Name,
+		":id", post.ID,
+		":category", categorySlug(post.Categories),
+	)
+	return replacer.Replace(pattern)
+}
+
+// URLPath joins root and a permalink into an absolute URL path.
+func URLPath(root string, permalink string) string {
+	p := path.Join("/", root, permalink)
+	if strings.HasSuffix(permalink, "/") && p != "/" {
+		p += "/"
+	}
+	return p
+}
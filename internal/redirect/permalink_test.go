@@ -0,0 +1,28 @@
+package redirect
+
+import (
+	"outline-hexo-connector/internal/generator"
+	"testing"
+)
+
+func TestPermalinkCategory(t *testing.T) {
+	tests := []struct {
+		categories []string
+		want       string
+	}{
+		{nil, "uncategorized/post/"},
+		{[]string{"Go"}, "Go/post/"},
+		{[]string{"Dev Notes", "Go & Rust"}, "Dev-Notes/Go-Rust/post/"},
+		{[]string{"a/b", "../c"}, "a-b/c/post/"},
+		{[]string{"Café"}, "Cafe/post/"},
+		{[]string{"编程", "笔记 一"}, "编程/笔记-一/post/"},
+		{[]string{"--", "Go"}, "Go/post/"},
+		{[]string{"??"}, "uncategorized/post/"},
+	}
+	for _, tt := range tests {
+		post := &generator.Post{Name: "post", Date: "2024-01-02T03:04:05.000", Categories: tt.categories}
+		if got := Permalink(":category/:title/", post); got != tt.want {
+			t.Errorf("Permalink(%q) = %q, want %q", tt.categories, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package redirect
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type Redirect struct {
+	From string
+	To   string
+}
+
+func escapePath(p string) string {
+	return (&url.URL{Path: p}).EscapedPath()
+}
+
+// WriteNetlify writes a _redirects file as understood by Netlify and Cloudflare Pages.
+func WriteNetlify(path string, redirects []Redirect) error {
+	var buf bytes.Buffer
+	buf.WriteString("# Generated by outline-hexo-connector, do not edit\n")
+	for _, r := range redirects {
+		fmt.Fprintf(&buf, "%s %s 301\n", escapePath(r.From), escapePath(r.To))
+	}
+	return writeFile(path, buf.Bytes())
+}
+
+// WriteNginxMap writes map entries to include from a map block, e.g.
+//
+//	map $uri $outline_redirect { include /path/to/redirects.nginx.conf; }
+func WriteNginxMap(path string, redirects []Redirect) error {
+	var buf bytes.Buffer
+	buf.WriteString("# Generated by outline-hexo-connector, do not edit\n")
+	for _, r := range redirects {
+		fmt.Fprintf(&buf, "%s %s;\n", nginxQuote(r.From), nginxQuote(escapePath(r.To)))
+	}
+	return writeFile(path, buf.Bytes())
+}
+
+func nginxQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+const stubTemplate = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Redirecting</title>
+<link rel="canonical" href="%[1]s">
+<meta http-equiv="refresh" content="0; url=%[1]s">
+</head>
+<body>
+<a href="%[1]s">%[1]s</a>
+</body>
+</html>
+`
+
+// stubPath returns where the stub page for an URL path goes below dir. Empty, . and ..
+// segments are dropped, a path that would still end up outside dir is rejected.
+func stubPath(dir string, from string) (string, error) {
+	var segments []string
+	for _, segment := range strings.Split(from, "/") {
+		if segment == "" || segment == "." || segment == ".." || strings.ContainsRune(segment, 0) {
+			continue
+		}
+		segments = append(segments, segment)
+	}
+	if len(segments) == 0 {
+		return "", fmt.Errorf("Redirect from the site root %q", from)
+	}
+
+	p := filepath.Join(dir, filepath.Join(segments...))
+	if strings.HasSuffix(from, "/") || filepath.Ext(p) == "" {
+		p = filepath.Join(p, "index.html")
+	}
+	if !insideDir(dir, p) {
+		return "", fmt.Errorf("Redirect stub %s outside of %s", p, dir)
+	}
+	return p, nil
+}
+
+// insideDir reports whether the cleaned path p is below dir.
+func insideDir(dir string, p string) bool {
+	rel, err := filepath.Rel(dir, p)
+	return err == nil && rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) && !filepath.IsAbs(rel)
+}
+
+// WriteStubs writes a meta refresh page for every redirect below dir. Stubs listed in
+// manifest from an earlier run that are no longer needed are removed, the new list is
+// saved to manifest. With layoutFalse the stubs get front matter that keeps Hexo from
+// wrapping them in the theme layout.
+func WriteStubs(dir string, manifest string, redirects []Redirect, layoutFalse bool) error {
+	var previous []string
+	content, err := os.ReadFile(manifest)
+	if err == nil {
+		err = json.Unmarshal(content, &previous)
+	}
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Printf("Error reading redirect stub manifest - %v", err)
+	}
+
+	written := make(map[string]bool)
+	var paths []string
+	for _, r := range redirects {
+		p, err := stubPath(dir, r.From)
+		if err != nil {
+			log.Printf("Error writing redirect stub - %v - Skipping", err)
+			continue
+		}
+		var buf bytes.Buffer
+		if layoutFalse {
+			buf.WriteString("---\nlayout: false\n---\n")
+		}
+		fmt.Fprintf(&buf, stubTemplate, html.EscapeString(escapePath(r.To)))
+
+		err = os.MkdirAll(filepath.Dir(p), 0755)
+		if err != nil {
+			return err
+		}
+		err = writeFile(p, buf.Bytes())
+		if err != nil {
+			return err
+		}
+		written[p] = true
+		paths = append(paths, p)
+	}
+
+	for _, p := range previous {
+		if written[p] || !insideDir(dir, filepath.Clean(p)) {
+			continue
+		}
+		err := os.Remove(p)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Printf("Error removing stale redirect stub - %v", err)
+		}
+	}
+
+	content, err = json.MarshalIndent(paths, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFile(manifest, content)
+}
+
+func writeFile(path string, content []byte) error {
+	tmpPath := path + ".tmp"
+	err := os.WriteFile(tmpPath, content, 0644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
@@ -0,0 +1,67 @@
+package redirect
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStubPath(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "public")
+	tests := []struct {
+		from string
+		want string
+	}{
+		{"/old/", "old/index.html"},
+		{"/old", "old/index.html"},
+		{"/old.html", "old.html"},
+		{"/2024/01/old/", "2024/01/old/index.html"},
+		{"/../../etc/passwd/", "etc/passwd/index.html"},
+		{"/a/../../b.html", "a/b.html"},
+		{"//a/./b/", "a/b/index.html"},
+	}
+	for _, tt := range tests {
+		got, err := stubPath(dir, tt.from)
+		if err != nil {
+			t.Errorf("stubPath(%q) - %v", tt.from, err)
+			continue
+		}
+		if want := filepath.Join(dir, filepath.FromSlash(tt.want)); got != want {
+			t.Errorf("stubPath(%q) = %q, want %q", tt.from, got, want)
+		}
+	}
+
+	for _, from := range []string{"/", "", "/../", "/./.."} {
+		if got, err := stubPath(dir, from); err == nil {
+			t.Errorf("stubPath(%q) = %q, want an error", from, got)
+		}
+	}
+}
+
+func TestWriteStubsStaysInDir(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "public")
+	outside := filepath.Join(root, "outside.html")
+	if err := os.WriteFile(outside, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	// A manifest listing a file outside the output directory
+	manifest := filepath.Join(root, "manifest.json")
+	if err := os.WriteFile(manifest, []byte(`["`+outside+`"]`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	redirects := []Redirect{{From: "/../outside/", To: "/new/"}, {From: "/", To: "/new/"}}
+	if err := WriteStubs(dir, manifest, redirects, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "outside", "index.html")); err != nil {
+		t.Errorf("stub not written below dir - %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "index.html")); err == nil {
+		t.Error("stub written over the site root")
+	}
+	if _, err := os.Stat(outside); err != nil {
+		t.Errorf("file outside of dir removed - %v", err)
+	}
+}
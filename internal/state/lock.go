@@ -0,0 +1,8 @@
+package state
+
+import "errors"
+
+const lockFileName = "connector.lock"
+
+// ErrLocked is returned by Lock when another process uses the data dir.
+var ErrLocked = errors.New("data dir is in use by another connector process")
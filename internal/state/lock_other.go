@@ -0,0 +1,9 @@
+//go:build !unix
+
+package state
+
+// Lock is not supported on this platform, the data dir is not guarded against a
+// second process.
+func Lock(dir string) (release func() error, err error) {
+	return func() error { return nil }, nil
+}
@@ -0,0 +1,28 @@
+//go:build unix
+
+package state
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestLockIsExclusive(t *testing.T) {
+	dir := t.TempDir()
+	release, err := Lock(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Lock(dir); !errors.Is(err, ErrLocked) {
+		t.Fatalf("second Lock = %v, want ErrLocked", err)
+	}
+
+	if err := release(); err != nil {
+		t.Fatal(err)
+	}
+	release, err = Lock(dir)
+	if err != nil {
+		t.Fatalf("Lock after release = %v", err)
+	}
+	release()
+}
@@ -0,0 +1,133 @@
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Document is what the connector remembers about a synced Outline document.
+type Document struct {
+	URLID              string            `json:"urlId,omitempty"`
+	UpdatedAt          string            `json:"updatedAt,omitempty"`
+	FileName           string            `json:"fileName,omitempty"`
+	Permalink          string            `json:"permalink,omitempty"`
+	PreviousPermalinks []string          `json:"previousPermalinks,omitempty"` // Redirected to Permalink
+	Attachments        map[string]string `json:"attachments,omitempty"`
+}
+
+// Store keeps per document state in a JSON file under the data dir.
+type Store struct {
+	mu     sync.Mutex
+	path   string
+	docs   map[string]Document
+	names  map[string]string // Post file name to document ID
+	urlIDs map[string]string // URL ID to document ID
+}
+
+func Open(dir string) (*Store, error) {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Store{
+		path:   filepath.Join(dir, "documents.json"),
+		docs:   make(map[string]Document),
+		names:  make(map[string]string),
+		urlIDs: make(map[string]string),
+	}
+	content, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	} else if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(content, &s.docs)
+	if err != nil {
+		return nil, err
+	}
+	for id, doc := range s.docs {
+		s.index(id, Document{}, doc)
+	}
+	return s, nil
+}
+
+func (s *Store) Get(id string) (Document, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	doc, ok := s.docs[id]
+	return doc, ok
+}
+
+// Owner returns the ID of the document whose post is written under fileName.
+func (s *Store) Owner(fileName string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id, ok := s.names[fileName]
+	return id, ok
+}
+
+// ByURLID returns the ID and state of the document with the URL ID urlID.
+func (s *Store) ByURLID(urlID string) (string, Document, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id, ok := s.urlIDs[urlID]
+	return id, s.docs[id], ok
+}
+
+// All returns a copy of the state of every known document.
+func (s *Store) All() map[string]Document {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	docs := make(map[string]Document, len(s.docs))
+	for id, doc := range s.docs {
+		docs[id] = doc
+	}
+	return docs
+}
+
+// Update applies fn to the state of document id and persists the result.
+func (s *Store) Update(id string, fn func(doc *Document)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	old := s.docs[id]
+	doc := old
+	fn(&doc)
+	s.docs[id] = doc
+	s.index(id, old, doc)
+	return s.save()
+}
+
+// index moves the lookups of document id from its old state to doc.
+func (s *Store) index(id string, old Document, doc Document) {
+	if old.FileName != doc.FileName && s.names[old.FileName] == id {
+		delete(s.names, old.FileName)
+	}
+	if doc.FileName != "" {
+		s.names[doc.FileName] = id
+	}
+	if old.URLID != doc.URLID && s.urlIDs[old.URLID] == id {
+		delete(s.urlIDs, old.URLID)
+	}
+	if doc.URLID != "" {
+		s.urlIDs[doc.URLID] = id
+	}
+}
+
+func (s *Store) save() error {
+	content, err := json.MarshalIndent(s.docs, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmpPath := s.path + ".tmp"
+	err = os.WriteFile(tmpPath, content, 0644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, s.path)
+}
@@ -0,0 +1,149 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"outline-hexo-connector/internal/outline"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Fixture is a recorded webhook request.
+type Fixture struct {
+	Method  string      `json:"method"`
+	Headers http.Header `json:"headers"`
+	Body    string      `json:"body"`
+}
+
+// fixtureSeq tells apart the fixtures saved within the same millisecond, keeping their order.
+var fixtureSeq atomic.Uint64
+
+// SaveFixture writes the request r with body into dir, named after the time, a sequence
+// number and the event.
+func SaveFixture(dir string, r *http.Request, body []byte) (string, error) {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return "", err
+	}
+
+	var webhook struct {
+		Event string `json:"event"`
+	}
+	json.Unmarshal(body, &webhook)
+	name := fmt.Sprintf("%s-%06d", time.Now().Format("20060102-150405.000"), fixtureSeq.Add(1))
+	if webhook.Event != "" {
+		name += "-" + webhook.Event
+	}
+
+	content, err := json.MarshalIndent(Fixture{
+		Method:  r.Method,
+		Headers: r.Header,
+		Body:    string(body),
+	}, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, name+".json")
+	// Never overwrite a fixture, such as one saved by an earlier run
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return "", err
+	}
+	_, err = file.Write(content)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return path, err
+}
+
+func LoadFixture(path string) (*Fixture, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	fixture := &Fixture{}
+	err = json.Unmarshal(content, fixture)
+	if err != nil {
+		return nil, err
+	}
+	return fixture, nil
+}
+
+// fixturePaths expands directories in paths to the fixtures inside them, in name order.
+func fixturePaths(paths []string) ([]string, error) {
+	var result []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			result = append(result, path)
+			continue
+		}
+		matches, err := filepath.Glob(filepath.Join(path, "*.json"))
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(matches)
+		result = append(result, matches...)
+	}
+	return result, nil
+}
+
+// Replay posts the fixtures at paths to url in order, signed with secret the way
+// Outline signs webhooks. It stops at the first fixture that is not accepted.
+func Replay(ctx context.Context, url string, secret string, paths []string) error {
+	paths, err := fixturePaths(paths)
+	if err != nil {
+		return err
+	}
+	if len(paths) == 0 {
+		return fmt.Errorf("No fixtures found")
+	}
+
+	client := &http.Client{Timeout: time.Second * 30}
+	for _, path := range paths {
+		fixture, err := LoadFixture(path)
+		if err != nil {
+			return fmt.Errorf("Error loading fixture %s - %w", path, err)
+		}
+
+		req, err := http.NewRequestWithContext(ctx, "POST", url, strings.NewReader(fixture.Body))
+		if err != nil {
+			return err
+		}
+		for key, values := range fixture.Headers {
+			switch http.CanonicalHeaderKey(key) {
+			case "Outline-Signature", "Content-Length", "Host", "Accept-Encoding":
+				continue
+			}
+			for _, value := range values {
+				req.Header.Add(key, value)
+			}
+		}
+		// The recorded signature has expired, sign again with the current time
+		req.Header.Set("Outline-Signature", outline.SignWebhook(secret, time.Now(), []byte(fixture.Body)))
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return fmt.Errorf("Error replaying fixture %s - %w", path, err)
+		}
+		respBody, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		log.Printf("Replayed %s - %d %s", filepath.Base(path), resp.StatusCode, string(bytes.TrimSpace(respBody)))
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return fmt.Errorf("Fixture %s was answered with %s", path, resp.Status)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestSaveFixtureKeepsEveryRequest(t *testing.T) {
+	dir := t.TempDir()
+	const count = 100
+	for i := 0; i < count; i++ {
+		r := httptest.NewRequest("POST", "/webhook", nil)
+		body := fmt.Sprintf(`{"event":"documents.update","n":%d}`, i)
+		if _, err := SaveFixture(dir, r, []byte(body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) != count {
+		t.Fatalf("saved %d fixtures, want %d", len(paths), count)
+	}
+	sort.Strings(paths)
+	for i, path := range paths {
+		fixture, err := LoadFixture(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := fmt.Sprintf(`"n":%d}`, i); !strings.HasSuffix(fixture.Body, want) {
+			t.Errorf("fixture %d is %s, want the request saved as number %d", i, fixture.Body, i)
+		}
+	}
+}
+
+func TestReplayStopsAtRejectedFixture(t *testing.T) {
+	dir := t.TempDir()
+	for _, event := range []string{"documents.publish", "documents.update", "documents.delete"} {
+		r := httptest.NewRequest("POST", "/webhook", nil)
+		if _, err := SaveFixture(dir, r, []byte(`{"event":"`+event+`"}`)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var received []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = append(received, r.Header.Get("Outline-Signature"))
+		if len(received) == 2 {
+			http.Error(w, "Invalid signature", http.StatusUnauthorized)
+		}
+	}))
+	defer server.Close()
+
+	err := Replay(context.Background(), server.URL, "secret", []string{dir})
+	if err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("Replay = %v, want the 401 reported", err)
+	}
+	if len(received) != 2 {
+		t.Errorf("replayed %d fixtures, want to stop after the second", len(received))
+	}
+}
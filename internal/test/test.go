@@ -1,12 +1,37 @@
 package test
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 )
 
+// RecordWebhook returns a handler printing incoming requests like PrintWebhook and
+// saving them as fixtures into dir, for replaying them later.
+func RecordWebhook(dir string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			log.Printf("Error reading webhook - %v", err)
+			http.Error(w, "Error reading webhook", http.StatusBadRequest)
+			return
+		}
+		defer r.Body.Close()
+
+		path, err := SaveFixture(dir, r, body)
+		if err != nil {
+			log.Printf("Error saving fixture - %v", err)
+		} else {
+			log.Printf("Fixture saved at %s", path)
+		}
+
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		PrintWebhook(w, r)
+	}
+}
+
 func PrintWebhook(w http.ResponseWriter, r *http.Request) {
 	log.Println("Received webhook request:")
 	fmt.Printf("Method: %s\n", r.Method)
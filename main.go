@@ -7,39 +7,98 @@ import (
 	"os"
 	"os/signal"
 	"outline-hexo-connector/internal/config"
-	"outline-hexo-connector/internal/hexo"
 	"outline-hexo-connector/internal/outline"
+	"outline-hexo-connector/internal/queue"
 	"outline-hexo-connector/internal/test"
 	"syscall"
 
 	flag "github.com/spf13/pflag"
 )
 
+// envOr returns the value of the environment variable OHC_<name>, or fallback if it is not set.
+func envOr(name string, fallback string) string {
+	if value, ok := os.LookupEnv(config.EnvPrefix + name); ok {
+		return value
+	}
+	return fallback
+}
+
 func main() {
 	log.SetFlags(log.LstdFlags | log.Lshortfile)
 
-	port := flag.StringP("port", "p", "9000", "Port to listen on for webhook requests")
+	port := flag.StringP("port", "p", envOr("PORT", "9000"), "Port to listen on for webhook requests")
 	isTestMode := flag.BoolP("test", "t", false, "Run in test mode to print raw incoming requests")
-	configFile := flag.StringP("config", "c", "config.yaml", "Path to config file")
+	configFile := flag.StringP("config", "c", envOr("CONFIG", "config.yaml"), "Path to config file")
+	fixtureDir := flag.StringP("fixtures", "f", "fixtures", "Directory test mode saves incoming requests into")
+	replayURL := flag.StringP("url", "u", "", "Webhook URL to replay fixtures to, default is the local connector on --port")
+	watchConfig := flag.DurationP("watch-config", "w", 0, "Reload the config when the file changes, checked at this interval, e.g. 5s")
+	siteName := flag.StringP("site", "s", "", "Site to render documents for, default is the first site")
 	flag.Parse()
 
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
 
+	switch flag.Arg(0) {
+	case "":
+	case "sync":
+		runSync(ctx, *configFile)
+		return
+	case "reconcile":
+		runReconcile(ctx, *configFile)
+		return
+	case "config":
+		if flag.Arg(1) != "check" {
+			log.Fatalf("Usage - config check")
+		}
+		runConfigCheck(*configFile)
+		return
+	case "render":
+		runRender(ctx, *configFile, *siteName, flag.Arg(1))
+		return
+	case "replay":
+		url := *replayURL
+		if url == "" {
+			url = "http://localhost:" + *port + "/webhook"
+		}
+		runReplay(ctx, *configFile, url, flag.Args()[1:])
+		return
+	default:
+		log.Fatalf("Unknown command - %s", flag.Arg(0))
+	}
+
 	if *isTestMode {
-		http.HandleFunc("/webhook", test.PrintWebhook)
-		log.Printf("Running in test mode - Print raw incoming requests only")
+		http.HandleFunc("/webhook", test.RecordWebhook(*fixtureDir))
+		log.Printf("Running in test mode - Print raw incoming requests and save them to %s", *fixtureDir)
 	} else {
-		cfg, err := config.LoadConfig(*configFile)
+		cfg := mustLoadConfig(*configFile)
+		defer mustLockDataDir(cfg)()
+
+		eventQueue, err := queue.Open(cfg.DataDir)
 		if err != nil {
-			log.Fatalf("Error loading config - %v", err)
+			log.Fatalf("Error opening event queue - %v", err)
 		}
-		log.Printf("Config loaded from %s", *configFile)
+		defer eventQueue.Close()
 
-		hexoTrigger := hexo.NewTrigger(cfg)
-		hexoTrigger.Watch(ctx)
-		outlineClient := outline.NewClient(cfg, hexoTrigger)
-		http.HandleFunc("/webhook", outlineClient.HandleWebhook)
+		sites := mustOpenSites(cfg)
+		var clients []*outline.Client
+		for _, s := range sites {
+			// Resolved again on the first webhook if Outline is not reachable now
+			go func(client *outline.Client) {
+				err := client.ResolveCollections(ctx)
+				if err != nil {
+					log.Printf("Error resolving blog collections - %v", err)
+				}
+			}(s.client)
+			s.generator.Watch(ctx)
+			s.client.WatchReconcile(ctx)
+			clients = append(clients, s.client)
+		}
+		router := outline.NewRouter(cfg, eventQueue, clients)
+		eventQueue.Start(ctx, cfg.QueueWorkers, func(entry queue.Entry) error {
+			return router.ProcessWebhook(ctx, entry.Data)
+		})
+		http.HandleFunc("/webhook", router.HandleWebhook)
+		go newReloader(*configFile, cfg, router, sites).Watch(ctx, *watchConfig)
 	}
 
 	go func() {
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"outline-hexo-connector/internal/config"
+	"outline-hexo-connector/internal/outline"
+	"outline-hexo-connector/internal/processor"
+	"syscall"
+	"time"
+)
+
+// reloader applies changes of the config file to the running sites.
+type reloader struct {
+	configFile string
+	cfg        *config.Config
+	router     *outline.Router
+	sites      map[string]*site
+	hup        chan os.Signal
+}
+
+func newReloader(configFile string, cfg *config.Config, router *outline.Router, sites []*site) *reloader {
+	r := &reloader{
+		configFile: configFile,
+		cfg:        cfg,
+		router:     router,
+		sites:      make(map[string]*site, len(sites)),
+		hup:        make(chan os.Signal, 1),
+	}
+	// Right away, SIGHUP would stop the process until then
+	signal.Notify(r.hup, syscall.SIGHUP)
+	for _, s := range sites {
+		r.sites[s.cfg.Name] = s
+	}
+	return r
+}
+
+// Watch reloads the config on SIGHUP, and when the file changes if poll is not zero,
+// until ctx is done.
+func (r *reloader) Watch(ctx context.Context, poll time.Duration) {
+	var tick <-chan time.Time
+	modified := r.modTime()
+	if poll > 0 {
+		ticker := time.NewTicker(poll)
+		tick = ticker.C
+		defer ticker.Stop()
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			signal.Stop(r.hup)
+			return
+		case <-r.hup:
+			log.Printf("SIGHUP received - Reloading config from %s", r.configFile)
+			modified = r.modTime()
+			r.reload()
+		case <-tick:
+			current := r.modTime()
+			if current.Equal(modified) {
+				continue
+			}
+			modified = current
+			log.Printf("Config file changed - Reloading config from %s", r.configFile)
+			r.reload()
+		}
+	}
+}
+
+func (r *reloader) modTime() time.Time {
+	info, err := os.Stat(r.configFile)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
+// keepStartupSettings puts back the settings only used when starting up, and returns
+// the keys of the ones that changed.
+func keepStartupSettings(running *config.Config, cfg *config.Config) []string {
+	var kept []string
+	if cfg.DataDir != running.DataDir {
+		kept = append(kept, "Data_Dir")
+		cfg.DataDir = running.DataDir
+	}
+	if cfg.QueueWorkers != running.QueueWorkers {
+		kept = append(kept, "Queue_Workers")
+		cfg.QueueWorkers = running.QueueWorkers
+	}
+	if cfg.OutlineReconcileInterval != running.OutlineReconcileInterval {
+		kept = append(kept, "Outline_Reconcile_Interval")
+		cfg.OutlineReconcileInterval = running.OutlineReconcileInterval
+	}
+	return kept
+}
+
+// reload loads and validates the config file and swaps it into every site at once.
+// Nothing is changed if the new config has problems for any site or needs a restart.
+func (r *reloader) reload() {
+	cfg, err := config.LoadConfig(r.configFile)
+	if err != nil {
+		log.Printf("Error reloading config - %v - Keeping the running config", err)
+		return
+	}
+	err = cfg.Validate()
+	if err != nil {
+		log.Printf("Invalid config %s - %v - Keeping the running config", r.configFile, err)
+		return
+	}
+	// Before the sites take their settings from cfg
+	for _, key := range keepStartupSettings(r.cfg, cfg) {
+		log.Printf("%s changed - Restart to apply", key)
+	}
+
+	type update struct {
+		site     *site
+		cfg      *config.Config
+		pipeline *processor.Pipeline
+		apply    func()
+	}
+	var updates []update
+	restart := false
+	seen := make(map[string]bool)
+	for _, siteCfg := range cfg.AllSites() {
+		seen[siteCfg.Name] = true
+		s, ok := r.sites[siteCfg.Name]
+		if !ok {
+			log.Printf("Site %s added - Restart to apply", siteCfg.Name)
+			restart = true
+			continue
+		}
+		if siteCfg.Generator != s.cfg.Generator {
+			log.Printf("Generator of site %s changed - Restart to apply", siteCfg.Name)
+			restart = true
+			continue
+		}
+		pipeline, err := processor.NewPipeline(siteCfg.ProcessorStages)
+		if err != nil {
+			log.Printf("Error setting up Markdown processing - %v - Keeping the running config", err)
+			return
+		}
+		u := update{site: s, cfg: cfg.ForSite(siteCfg), pipeline: pipeline}
+		u.apply, err = s.generator.Reconfigure(u.cfg)
+		if err != nil {
+			log.Printf("Error reconfiguring site %s - %v - Keeping the running config", siteCfg.Name, err)
+			return
+		}
+		updates = append(updates, u)
+	}
+	for name := range r.sites {
+		if !seen[name] {
+			log.Printf("Site %s removed - Restart to apply", name)
+			restart = true
+		}
+	}
+	if restart {
+		log.Printf("Sites changed - Keeping the running config until restart")
+		return
+	}
+
+	changes := config.Diff(r.cfg, cfg)
+	if len(changes) == 0 {
+		log.Printf("Config reloaded - No changes")
+		return
+	}
+	for _, change := range changes {
+		log.Printf("Config changed - %s", change)
+	}
+
+	// Nothing below fails, every site switches or none does
+	for _, u := range updates {
+		u.apply()
+		u.site.client.Reconfigure(u.cfg, u.pipeline)
+		u.site.cfg = u.cfg
+	}
+	r.router.Reconfigure(cfg)
+	r.cfg = cfg
+	log.Printf("Config reloaded from %s", r.configFile)
+}
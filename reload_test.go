@@ -0,0 +1,78 @@
+package main
+
+import (
+	"os"
+	"outline-hexo-connector/internal/config"
+	"outline-hexo-connector/internal/outline"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeConfig(t *testing.T, path string, dir string, template string) {
+	t.Helper()
+	content := strings.NewReplacer("DIR", dir, "TEMPLATE", template).Replace(`
+Outline_API_URL: http://localhost/api
+Outline_API_Key: key
+Outline_Webhook_Secret: secret
+Data_Dir: DIR/data
+Hexo_Build_Command: old build
+Hexo_Build_Interval: 30
+Sites:
+  - Name: one
+    Outline_Collection_Used_For_Blog: One
+    Hexo_Source_Post_Dir: DIR/one
+  - Name: two
+    Outline_Collection_Used_For_Blog: Two
+    Hexo_Source_Post_Dir: DIR/two
+    Hexo_Post_Template: TEMPLATE
+`)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReloadSwapsAllSitesOrNone(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"one", "two"} {
+		if err := os.Mkdir(filepath.Join(dir, name), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	path := filepath.Join(dir, "config.yaml")
+	writeConfig(t, path, dir, "")
+	cfg, err := config.LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	sites := mustOpenSites(cfg)
+	var clients []*outline.Client
+	for _, s := range sites {
+		clients = append(clients, s.client)
+	}
+	r := newReloader(path, cfg, outline.NewRouter(cfg, nil, clients), sites)
+
+	// Site two fails with a template that does not exist, site one is left alone too
+	t.Setenv("OHC_HEXO_BUILD_COMMAND", "new build")
+	writeConfig(t, path, dir, filepath.Join(dir, "missing.tmpl"))
+	r.reload()
+	for _, s := range sites {
+		if s.cfg.HexoBuildCommand != "old build" {
+			t.Errorf("site %s switched to %q with site two failing", s.cfg.Name, s.cfg.HexoBuildCommand)
+		}
+	}
+	if r.cfg != cfg {
+		t.Error("running config swapped with site two failing")
+	}
+
+	writeConfig(t, path, dir, "")
+	r.reload()
+	for _, s := range sites {
+		if s.cfg.HexoBuildCommand != "new build" {
+			t.Errorf("site %s kept %q", s.cfg.Name, s.cfg.HexoBuildCommand)
+		}
+	}
+}